@@ -0,0 +1,236 @@
+package tree
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type BackupStyle int
+
+const (
+	BackupNumbered  BackupStyle = iota // config.json.1, config.json.2, ...
+	BackupTimestamp                    // config.json.20250610-115741.000000000
+	BackupDir                          // .history/config.json.20250610-115741.000000000
+)
+
+const backupLayout = "20060102-150405.000000000"
+
+type Version struct {
+	ID   string
+	File string
+	Time time.Time
+}
+
+func copyFile(src string, dst string) error {
+	fs, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer fs.Close()
+
+	fd, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(fd, fs)
+	if cerr := fd.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// withSidecars is the file and its checksum and signature files.
+func withSidecars(fileName string) []string {
+	return []string{fileName, fileName + ".sha256", fileName + ".sig"}
+}
+
+// copyFiles copies a file with its sidecars, missing sidecars are skipped.
+func copyFiles(src string, dst string) error {
+	s, d := withSidecars(src), withSidecars(dst)
+	for i := range s {
+		if i > 0 {
+			os.Remove(d[i])
+			if _, err := os.Stat(s[i]); err != nil {
+				continue
+			}
+		}
+		err := copyFile(s[i], d[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renameFiles renames a file with its sidecars.
+func renameFiles(src string, dst string) error {
+	s, d := withSidecars(src), withSidecars(dst)
+	for i := range s {
+		os.Remove(d[i])
+		if _, err := os.Stat(s[i]); err != nil {
+			continue
+		}
+		err := os.Rename(s[i], d[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (root *Tree) backupPrefix(fileName string) string {
+	if root.BackupStyle == BackupDir {
+		dir, fn := filepath.Split(fileName)
+		return filepath.Join(dir, ".history", fn) + "."
+	}
+	return fileName + "."
+}
+
+func (root *Tree) backup(fileName string) error {
+	if root.Backups <= 0 {
+		return nil
+	}
+
+	_, err := os.Stat(fileName)
+	if err != nil {
+		return nil
+	}
+
+	prefix := root.backupPrefix(fileName)
+	if root.BackupStyle == BackupNumbered {
+		removeFile(prefix + strconv.Itoa(root.Backups))
+		for i := root.Backups - 1; i > 0; i-- {
+			old := prefix + strconv.Itoa(i)
+			if _, err := os.Stat(old); err == nil {
+				err = renameFiles(old, prefix+strconv.Itoa(i+1))
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return copyFiles(fileName, prefix+"1")
+	}
+
+	err = os.MkdirAll(filepath.Dir(prefix), 0755)
+	if err != nil {
+		return err
+	}
+
+	err = copyFiles(fileName, prefix+time.Now().Format(backupLayout))
+	if err != nil {
+		return err
+	}
+
+	list, err := root.versions(fileName)
+	if err != nil {
+		return err
+	}
+	for i := root.Backups; i < len(list); i++ {
+		removeFile(list[i].File)
+	}
+
+	return nil
+}
+
+func (root *Tree) versions(fileName string) ([]Version, error) {
+	var list []Version
+
+	prefix := root.backupPrefix(fileName)
+	files, err := filepath.Glob(prefix + "*")
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range files {
+		id := strings.TrimPrefix(files[i], prefix)
+		if strings.Contains(id, ".tmp") {
+			continue
+		}
+
+		v := Version{ID: id, File: files[i]}
+		if root.BackupStyle == BackupNumbered {
+			if _, err := strconv.Atoi(id); err != nil {
+				continue
+			}
+		} else {
+			v.Time, err = time.ParseInLocation(backupLayout, id, time.Local)
+			if err != nil {
+				continue
+			}
+		}
+
+		if v.Time.IsZero() {
+			fi, err := os.Stat(files[i])
+			if err != nil {
+				continue
+			}
+			v.Time = fi.ModTime()
+		}
+		list = append(list, v)
+	}
+
+	// newest first
+	sort.SliceStable(list, func(i, j int) bool {
+		if root.BackupStyle == BackupNumbered {
+			a, _ := strconv.Atoi(list[i].ID)
+			b, _ := strconv.Atoi(list[j].ID)
+			return a < b
+		}
+		return list[i].Time.After(list[j].Time)
+	})
+	return list, nil
+}
+
+func (root *Tree) ListVersions() ([]Version, error) {
+	if root.fileName == "" {
+		return nil, fmt.Errorf("file name is blank")
+	}
+
+	return root.versions(root.fileName)
+}
+
+func (root *Tree) RestoreVersion(id string) error {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	list, err := root.ListVersions()
+	if err != nil {
+		return err
+	}
+
+	for i := range list {
+		if list[i].ID != id {
+			continue
+		}
+
+		var base *twig
+		if root.binary {
+			base, err = root.readBinary(list[i].File)
+		} else {
+			base, err = root.read(list[i].File, true)
+		}
+		if err != nil {
+			return err
+		}
+		err = root.expand(base, filepath.Dir(root.fileName), map[string]bool{root.fileName: true}, false)
+		if err != nil {
+			return err
+		}
+
+		before, err := root.checkpoint()
+		if err != nil {
+			return err
+		}
+		root.Base = base
+		return root.recordSince(before)
+	}
+
+	return fmt.Errorf("not found version: %v", id)
+}
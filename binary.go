@@ -0,0 +1,342 @@
+package tree
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// binary layout: "TREE", version, then every node as
+// name, kind tag, value, desc, count of childs, childs.
+// strings and byte values are prefixed with their uvarint length.
+const binaryMagic = "TREE\x01"
+
+const (
+	tagNull byte = iota
+	tagString
+	tagInteger
+	tagFloat
+	tagBool
+	tagDatetime
+	tagOther // kind name followed by a string value
+)
+
+func appendBytes(buf []byte, b []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(b)))
+	return append(buf, b...)
+}
+
+func (tw *twig) appendBinary(buf []byte) ([]byte, error) {
+	buf = appendBytes(buf, []byte(tw.Name))
+
+	switch tw.Kind {
+	case "":
+		buf = append(buf, tagNull)
+	case "string":
+		buf = append(buf, tagString)
+		buf = appendBytes(buf, []byte(tw.get("string").(string)))
+	case "integer":
+		buf = append(buf, tagInteger)
+		buf = binary.AppendVarint(buf, tw.get("integer").(int64))
+	case "float":
+		buf = append(buf, tagFloat)
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(tw.get("float").(float64)))
+	case "bool":
+		buf = append(buf, tagBool)
+		if tw.get("bool").(bool) {
+			buf = append(buf, 1)
+		} else {
+			buf = append(buf, 0)
+		}
+	case "datetime":
+		tt, ok := tw.get("datetime").(time.Time)
+		if !ok {
+			return nil, fmt.Errorf("do not match kind: %v", tw.Name)
+		}
+		b, err := tt.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, tagDatetime)
+		buf = appendBytes(buf, b)
+	default:
+		s, _ := tw.Value.(string)
+		buf = append(buf, tagOther)
+		buf = appendBytes(buf, []byte(tw.Kind))
+		buf = appendBytes(buf, []byte(s))
+	}
+
+	buf = appendBytes(buf, []byte(tw.Desc))
+	buf = binary.AppendUvarint(buf, uint64(len(tw.Childs)))
+
+	var err error
+	for i := range tw.Childs {
+		buf, err = tw.Childs[i].appendBinary(buf)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+type binaryReader struct {
+	buf []byte
+	pos int
+}
+
+func (br *binaryReader) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(br.buf[br.pos:])
+	if n <= 0 {
+		return 0, fmt.Errorf("binary is broken at %v", br.pos)
+	}
+	br.pos += n
+	return v, nil
+}
+
+func (br *binaryReader) bytes() ([]byte, error) {
+	n, err := br.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(br.buf)-br.pos) < n {
+		return nil, fmt.Errorf("binary is broken at %v", br.pos)
+	}
+
+	b := br.buf[br.pos : br.pos+int(n)]
+	br.pos += int(n)
+	return b, nil
+}
+
+func (br *binaryReader) next() (byte, error) {
+	if br.pos >= len(br.buf) {
+		return 0, fmt.Errorf("binary is broken at %v", br.pos)
+	}
+	br.pos++
+	return br.buf[br.pos-1], nil
+}
+
+func (br *binaryReader) twig() (twig, error) {
+	var tw twig
+
+	b, err := br.bytes()
+	if err != nil {
+		return tw, err
+	}
+	tw.Name = string(b)
+
+	tag, err := br.next()
+	if err != nil {
+		return tw, err
+	}
+
+	switch tag {
+	case tagNull:
+	case tagString:
+		b, err = br.bytes()
+		tw.Kind = "string"
+		tw.Value = string(b)
+	case tagInteger:
+		v, n := binary.Varint(br.buf[br.pos:])
+		if n <= 0 {
+			return tw, fmt.Errorf("binary is broken at %v", br.pos)
+		}
+		br.pos += n
+		tw.Kind = "integer"
+		tw.Value = v
+	case tagFloat:
+		if len(br.buf)-br.pos < 8 {
+			return tw, fmt.Errorf("binary is broken at %v", br.pos)
+		}
+		tw.Kind = "float"
+		tw.Value = math.Float64frombits(binary.LittleEndian.Uint64(br.buf[br.pos:]))
+		br.pos += 8
+	case tagBool:
+		var c byte
+		c, err = br.next()
+		tw.Kind = "bool"
+		tw.Value = c != 0
+	case tagDatetime:
+		b, err = br.bytes()
+		if err == nil {
+			var tt time.Time
+			err = tt.UnmarshalBinary(b)
+			tw.setKind(tt)
+		}
+	case tagOther:
+		b, err = br.bytes()
+		tw.Kind = string(b)
+		if err == nil {
+			b, err = br.bytes()
+			tw.Value = string(b)
+		}
+	default:
+		return tw, fmt.Errorf("not found tag: %v", tag)
+	}
+	if err != nil {
+		return tw, err
+	}
+
+	b, err = br.bytes()
+	if err != nil {
+		return tw, err
+	}
+	tw.Desc = string(b)
+
+	n, err := br.uvarint()
+	if err != nil {
+		return tw, err
+	}
+	if n > uint64(len(br.buf)-br.pos) {
+		return tw, fmt.Errorf("binary is broken at %v", br.pos)
+	}
+
+	for i := uint64(0); i < n; i++ {
+		ctw, err := br.twig()
+		if err != nil {
+			return tw, err
+		}
+		tw.Childs = append(tw.Childs, ctw)
+	}
+	return tw, nil
+}
+
+func (root *Tree) MarshalBinary() ([]byte, error) {
+	if root.Base == nil {
+		return nil, fmt.Errorf("root is NULL")
+	}
+	if root.partial {
+		return nil, fmt.Errorf("tree is partially loaded: %v", root.fileName)
+	}
+
+	err := root.Base.loadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := root.export()
+	if err != nil {
+		return nil, err
+	}
+	return base.appendBinary([]byte(binaryMagic))
+}
+
+func (root *Tree) UnmarshalBinary(data []byte) error {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	return root.unmarshalBinary(data)
+}
+
+func (root *Tree) unmarshalBinary(data []byte) error {
+	base, err := decodeBinary(data)
+	if err != nil {
+		return err
+	}
+
+	root.Base = base
+	root.version++
+	root.Indent, _ = root.GetValueStr([]string{"options", "indent"})
+	return nil
+}
+
+func decodeBinary(data []byte) (*twig, error) {
+	if !bytes.HasPrefix(data, []byte(binaryMagic)) {
+		return nil, fmt.Errorf("not tree binary")
+	}
+
+	br := binaryReader{buf: data, pos: len(binaryMagic)}
+	base, err := br.twig()
+	if err != nil {
+		return nil, err
+	}
+	return &base, nil
+}
+
+// readBinary is read for binary files.
+func (root *Tree) readBinary(fileName string) (*twig, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	err = root.verify(fileName, sum[:])
+	if err != nil {
+		return nil, err
+	}
+
+	data, err = root.unseal(data)
+	if err != nil {
+		return nil, err
+	}
+	return decodeBinary(data)
+}
+
+func (root *Tree) SaveBinary(fileName string) error {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	fileName, err := fullPath(fileName)
+	if err != nil {
+		return err
+	}
+
+	buf, err := root.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	err = root.storeIncludes(root.Base, filepath.Dir(fileName))
+	if err != nil {
+		return err
+	}
+
+	buf, err = root.seal(fileName, buf)
+	if err != nil {
+		return err
+	}
+
+	return root.store(fileName, buf)
+}
+
+func (root *Tree) OpenBinary(fileName string) error {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	fileName, err := fullPath(fileName)
+	if err != nil {
+		return err
+	}
+
+	base, err := root.readBinary(fileName)
+	if err == nil {
+		err = root.expand(base, filepath.Dir(fileName), map[string]bool{fileName: true}, false)
+	}
+	if err != nil {
+		return err
+	}
+
+	root.Base = base
+	root.version++
+	root.Indent, _ = root.GetValueStr([]string{"options", "indent"})
+	root.fileName = fileName
+	root.binary = true
+	root.partial = false
+	root.journaled = 0
+
+	err = root.replay()
+	if err != nil {
+		return err
+	}
+
+	if root.hist != nil {
+		root.hist.reset()
+	}
+	root.markSaved()
+	return nil
+}
@@ -0,0 +1,138 @@
+package tree
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func benchTree() *Tree {
+	root := &Tree{Base: &twig{Name: "root"}}
+	now := time.Now()
+	for i := 0; i < 1000; i++ {
+		name := "n" + strconv.Itoa(i)
+		root.AddNew(name, nil, nil)
+
+		dst := []string{name}
+		root.AddNew("s", "value "+strconv.Itoa(i), dst)
+		root.AddNew("i", int64(i), dst)
+		root.AddNew("f", float64(i)/3, dst)
+		root.AddNew("b", i%2 == 0, dst)
+		root.AddNew("t", now.Add(time.Duration(i)*time.Second), dst)
+	}
+	return root
+}
+
+func TestMarshalBinary(t *testing.T) {
+	key := make([]byte, 32)
+	rand.Read(key)
+
+	root := &Tree{Base: &twig{Name: "root"}}
+	root.Keys = KeyFunc(func() ([]byte, error) { return key, nil })
+	root.AddNew("s", "text", nil)
+	root.AddNew("i", int64(-42), nil)
+	root.AddNew("f", 3.25, nil)
+	root.AddNew("b", true, nil)
+	root.AddNew("t", time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC), nil)
+	root.AddNew("n", nil, nil)
+	root.AddSecret("pw", "hunter2", nil)
+	root.AddNew("r", Ref("s"), nil)
+	root.Base.add(twig{Name: "inc", Kind: "include", Value: "inc.json"})
+	root.SetDesc("a string", []string{"s"})
+
+	buf, err := root.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var r Tree
+	err = r.UnmarshalBinary(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, _ := json.Marshal(root.Base)
+	got, _ := json.Marshal(r.Base)
+	if !bytes.Equal(got, want) {
+		t.Fatalf("%s != %s", got, want)
+	}
+	if len(r.Base.Childs) != 9 {
+		t.Fatalf("%+v", r.Base.Childs)
+	}
+	for i := range root.Base.Childs {
+		a, b := root.Base.Childs[i], r.Base.Childs[i]
+		if (a.Kind != b.Kind) || (a.Value != b.Value) || (a.Desc != b.Desc) {
+			t.Fatalf("%+v != %+v", b, a)
+		}
+	}
+
+	for n := 0; n < len(buf); n++ {
+		err = r.UnmarshalBinary(buf[:n])
+		if err == nil {
+			t.Fatalf("truncated to %v is read", n)
+		}
+	}
+}
+
+func BenchmarkMarshal(b *testing.B) {
+	root := benchTree()
+
+	b.Run("binary", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			buf, err := root.MarshalBinary()
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.SetBytes(int64(len(buf)))
+		}
+	})
+	b.Run("json", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			buf, err := json.Marshal(root.Base)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.SetBytes(int64(len(buf)))
+		}
+	})
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	root := benchTree()
+
+	b.Run("binary", func(b *testing.B) {
+		buf, err := root.MarshalBinary()
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.SetBytes(int64(len(buf)))
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			var t Tree
+			err = t.UnmarshalBinary(buf)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("json", func(b *testing.B) {
+		buf, err := json.Marshal(root.Base)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.SetBytes(int64(len(buf)))
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			var base *twig
+			err = json.Unmarshal(buf, &base)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/mususu247/tree"
+)
+
+const browseHelp = `  <n>            expand or collapse node n
+  e <n> [kind]   edit the value of node n, the kind is kept unless given
+  a <n> <name>   add a child to node n (0 is the root)
+  d <n>          delete node n
+  /<text>        search names and values
+  u, r           undo, redo
+  w              save
+  q              quit
+  ?              help
+`
+
+type browser struct {
+	root     *tree.Tree
+	in       *bufio.Scanner
+	out      io.Writer
+	expanded map[string]bool
+	visible  [][]string
+}
+
+func key(names []string) string {
+	return strings.Join(names, "/")
+}
+
+func (b *browser) value(names []string) (string, string) {
+	kind, _ := b.root.GetKind(names)
+	switch kind {
+	case "":
+		return "", ""
+	case "secret":
+		return "******", kind
+	}
+
+	v, _ := b.root.GetValueStr(names)
+	if kind == "string" {
+		v = strconv.Quote(v)
+	}
+	return v, kind
+}
+
+// ref reports whether names is a ref node, List follows refs so their childs are not walked.
+func (b *browser) ref(names []string) bool {
+	_, err := b.root.GetRef(names)
+	return err == nil
+}
+
+func (b *browser) render(names []string, depth int) {
+	list, _ := b.root.List(names)
+	for i := range list {
+		path := append(append([]string{}, names...), list[i])
+
+		var childs []string
+		if !b.ref(path) {
+			childs, _ = b.root.List(path)
+		}
+
+		mark := " "
+		if len(childs) != 0 {
+			mark = "+"
+			if b.expanded[key(path)] {
+				mark = "-"
+			}
+		}
+
+		b.visible = append(b.visible, path)
+		line := fmt.Sprintf("%4d %v%v %v", len(b.visible), strings.Repeat("  ", depth), mark, list[i])
+		if v, kind := b.value(path); kind != "" {
+			line += " = " + v + " (" + kind + ")"
+		}
+		fmt.Fprintln(b.out, line)
+
+		if (len(childs) != 0) && b.expanded[key(path)] {
+			b.render(path, depth+1)
+		}
+	}
+}
+
+func (b *browser) show() {
+	b.visible = nil
+
+	mark := ""
+	if b.root.Modified() {
+		mark = " *"
+	}
+	fmt.Fprintf(b.out, "   0 root%v\n", mark)
+	b.render([]string{}, 1)
+}
+
+func (b *browser) node(s string) ([]string, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, fmt.Errorf("not a node number: %v", s)
+	}
+	if n == 0 {
+		return []string{}, nil
+	}
+	if (n < 0) || (n > len(b.visible)) {
+		return nil, fmt.Errorf("not found node: %v", n)
+	}
+	return b.visible[n-1], nil
+}
+
+func (b *browser) prompt(text string) (string, bool) {
+	fmt.Fprint(b.out, text)
+	if !b.in.Scan() {
+		return "", false
+	}
+	return b.in.Text(), true
+}
+
+func (b *browser) edit(names []string, kind string) error {
+	if kind == "" {
+		kind, _ = b.root.GetKind(names)
+	}
+	if kind == "" {
+		kind = "string"
+	}
+
+	s, ok := b.prompt(fmt.Sprintf("%v (%v)> ", key(names), kind))
+	if !ok {
+		return nil
+	}
+	if kind == "secret" {
+		return b.root.SetSecret(s, names)
+	}
+
+	v, err := tree.ParseValue(kind, s)
+	if err != nil {
+		return fmt.Errorf("not a valid %v: %v", kind, s)
+	}
+	return b.root.SetValue(v, names)
+}
+
+func (b *browser) search(text string, names []string, found *[][]string) {
+	list, _ := b.root.List(names)
+	for i := range list {
+		path := append(append([]string{}, names...), list[i])
+
+		v, _ := b.value(path)
+		if strings.Contains(list[i], text) || strings.Contains(v, text) {
+			*found = append(*found, path)
+			for j := 1; j < len(path); j++ {
+				b.expanded[key(path[:j])] = true
+			}
+		}
+		if !b.ref(path) {
+			b.search(text, path, found)
+		}
+	}
+}
+
+func (b *browser) command(line string) (bool, error) {
+	f := strings.Fields(line)
+	if len(f) == 0 {
+		return false, nil
+	}
+
+	if strings.HasPrefix(line, "/") {
+		var found [][]string
+		b.search(strings.TrimPrefix(line, "/"), []string{}, &found)
+		for i := range found {
+			fmt.Fprintln(b.out, "  "+key(found[i]))
+		}
+		fmt.Fprintf(b.out, "%v found\n", len(found))
+		return false, nil
+	}
+
+	switch f[0] {
+	case "q":
+		if b.root.Modified() {
+			s, _ := b.prompt("not saved, quit? (y/n)> ")
+			if !strings.HasPrefix(s, "y") {
+				return false, nil
+			}
+		}
+		return true, nil
+	case "?", "h":
+		fmt.Fprint(b.out, browseHelp)
+		return false, nil
+	case "w":
+		return false, b.root.Save()
+	case "u":
+		return false, b.root.Undo()
+	case "r":
+		return false, b.root.Redo()
+	}
+
+	if len(f) < 2 {
+		names, err := b.node(f[0])
+		if err != nil {
+			return false, err
+		}
+		b.expanded[key(names)] = !b.expanded[key(names)]
+		return false, nil
+	}
+
+	names, err := b.node(f[1])
+	if err != nil {
+		return false, err
+	}
+
+	switch f[0] {
+	case "e":
+		kind := ""
+		if len(f) > 2 {
+			kind = f[2]
+		}
+		return false, b.edit(names, kind)
+	case "a":
+		if len(f) < 3 {
+			return false, fmt.Errorf("name is blank")
+		}
+		b.expanded[key(names)] = true
+		return false, b.root.AddNew(f[2], nil, names)
+	case "d":
+		return false, b.root.Delete(names)
+	}
+
+	return false, fmt.Errorf("not found command: %v", f[0])
+}
+
+func browse(root *tree.Tree, in io.Reader, out io.Writer) error {
+	b := browser{
+		root:     root,
+		in:       bufio.NewScanner(in),
+		out:      out,
+		expanded: make(map[string]bool),
+	}
+	root.EnableHistory(100)
+
+	fmt.Fprintln(out, "? for help")
+	for {
+		b.show()
+
+		line, ok := b.prompt("> ")
+		if !ok {
+			return nil
+		}
+
+		quit, err := b.command(strings.TrimSpace(line))
+		if err != nil {
+			fmt.Fprintf(out, "(Error) %v\n", err)
+		}
+		if quit {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mususu247/tree"
+)
+
+const usage = `usage: tree <command> [flags] file [args]
+
+commands:
+  get      file path              print a value
+  set      file path value        set a value (--kind, default: kind of the node)
+  add      file path [value]      add a node, the last name of path is the new name (--kind)
+  rm       file path              delete a node
+  cp       file src dst           copy the childs of src to dst
+  mv       file src dst           move the childs of src to dst
+  ls       file [path]            list child names
+  dump     file [path]            print the tree (--kinds)
+  validate file                   check the file can be read (--pub for signatures)
+  diff     file1 file2            show the changes (--json)
+  convert  src dst                write src as dst, ".tree" is binary, ".gz" is compressed (--resolve-refs)
+  browse   file                   browse and edit interactively
+
+path is slash separated, e.g. work/work_0/val_1
+kinds: string, integer, float, bool, datetime, secret, ref, include, null
+`
+
+type options struct {
+	fs      *flag.FlagSet
+	kind    string
+	kinds   bool
+	json    bool
+	pub     string
+	keyEnv  string
+	keyFile string
+	resolve bool
+}
+
+func split(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return []string{}
+	}
+	return strings.Split(path, "/")
+}
+
+func binaryFile(fileName string) bool {
+	ext := filepath.Ext(fileName)
+	if (ext == ".gz") || (ext == ".zst") {
+		ext = filepath.Ext(strings.TrimSuffix(fileName, ext))
+	}
+	return ext == ".tree"
+}
+
+func (o *options) open(fileName string) (*tree.Tree, error) {
+	var root tree.Tree
+
+	// tree.Open resolves bare names next to the executable, use the working directory
+	fileName, err := filepath.Abs(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	// keep an existing checksum in step with what set and rm write
+	if _, err := os.Stat(fileName + ".sha256"); err == nil {
+		root.Checksum = true
+		root.Verify = tree.VerifyReject
+	}
+	if o.keyEnv != "" {
+		root.Keys = tree.EnvKey(o.keyEnv)
+	}
+	if o.keyFile != "" {
+		root.Keys = tree.FileKey(o.keyFile)
+	}
+
+	if binaryFile(fileName) {
+		err = root.OpenBinary(fileName)
+	} else {
+		err = root.Open(fileName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &root, nil
+}
+
+func run(cmd string, args []string) error {
+	o := options{fs: flag.NewFlagSet(cmd, flag.ContinueOnError)}
+	o.fs.StringVar(&o.kind, "kind", "", "kind of the value")
+	o.fs.BoolVar(&o.kinds, "kinds", false, "print kinds and descriptions")
+	o.fs.BoolVar(&o.json, "json", false, "print JSON")
+	o.fs.StringVar(&o.pub, "pub", "", "file with a base64 Ed25519 public key")
+	o.fs.StringVar(&o.keyEnv, "key-env", "", "environment variable with the base64 AES key")
+	o.fs.StringVar(&o.keyFile, "key-file", "", "file with the base64 AES key")
+	o.fs.BoolVar(&o.resolve, "resolve-refs", false, "write the targets of refs instead of the refs")
+
+	err := o.fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	args = o.fs.Args()
+
+	need := map[string]int{
+		"get": 2, "set": 3, "add": 2, "rm": 2, "cp": 3, "mv": 3,
+		"ls": 1, "dump": 1, "validate": 1, "diff": 2, "convert": 2, "browse": 1,
+	}
+	n, ok := need[cmd]
+	if !ok {
+		return fmt.Errorf("not found command: %v", cmd)
+	}
+	if len(args) < n {
+		return fmt.Errorf("%v: missing arguments", cmd)
+	}
+
+	if cmd == "diff" {
+		a, err := o.open(args[0])
+		if err != nil {
+			return err
+		}
+		b, err := o.open(args[1])
+		if err != nil {
+			return err
+		}
+
+		changes, err := tree.Diff(a, b)
+		if err != nil {
+			return err
+		}
+
+		if o.json {
+			buf, err := tree.DiffJSON(changes, "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(buf))
+			return nil
+		}
+		fmt.Print(tree.DiffText(changes))
+		return nil
+	}
+
+	if cmd == "validate" {
+		fileName, err := filepath.Abs(args[0])
+		if err != nil {
+			return err
+		}
+
+		var root tree.Tree
+		if _, err := os.Stat(fileName + ".sha256"); err == nil {
+			root.Verify = tree.VerifyReject
+		}
+		if o.pub != "" {
+			buf, err := os.ReadFile(o.pub)
+			if err != nil {
+				return err
+			}
+			key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(buf)))
+			if err != nil {
+				return err
+			}
+			root.VerifyKey = ed25519.PublicKey(key)
+			root.Verify = tree.VerifyReject
+		}
+		if o.keyEnv != "" {
+			root.Keys = tree.EnvKey(o.keyEnv)
+		}
+		if o.keyFile != "" {
+			root.Keys = tree.FileKey(o.keyFile)
+		}
+
+		if binaryFile(fileName) {
+			err = root.OpenBinary(fileName)
+		} else {
+			err = root.Open(fileName)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Println("ok")
+		return nil
+	}
+
+	root, err := o.open(args[0])
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	var names []string
+	if len(args) > 1 {
+		names = split(args[1])
+	}
+
+	switch cmd {
+	case "get":
+		v, err := root.GetValueStr(names)
+		if err != nil {
+			return err
+		}
+		fmt.Println(v)
+		return nil
+	case "ls":
+		list, err := root.List(names)
+		if err != nil {
+			return err
+		}
+		for i := range list {
+			fmt.Println(list[i])
+		}
+		return nil
+	case "dump":
+		s, err := root.Sprint(names, o.kinds)
+		if err != nil {
+			return err
+		}
+		fmt.Print(s)
+		return nil
+	case "convert":
+		dst, err := filepath.Abs(args[1])
+		if err != nil {
+			return err
+		}
+		root.ResolveRefs = o.resolve
+		if binaryFile(dst) {
+			return root.SaveBinary(dst)
+		}
+		return root.SaveAs(dst)
+	case "browse":
+		return browse(root, os.Stdin, os.Stdout)
+	}
+
+	switch cmd {
+	case "set":
+		var kind string
+		kind, err = root.GetKind(names)
+		if err != nil {
+			return err
+		}
+
+		// a secret node stays encrypted whatever kind is given
+		if (o.kind == "secret") || (kind == "secret") {
+			err = root.SetSecret(args[2], names)
+			break
+		}
+
+		if o.kind != "" {
+			kind = o.kind
+		}
+
+		var v any
+		v, err = tree.ParseValue(kind, args[2])
+		if err != nil {
+			return err
+		}
+		err = root.SetValue(v, names)
+	case "add":
+		if len(names) == 0 {
+			return fmt.Errorf("add: path is blank")
+		}
+		name := names[len(names)-1]
+		dst := names[:len(names)-1]
+
+		var v any
+		if len(args) > 2 {
+			if o.kind == "secret" {
+				err = root.AddSecret(name, args[2], dst)
+				break
+			}
+			v, err = tree.ParseValue(o.kind, args[2])
+			if err != nil {
+				return err
+			}
+		}
+		err = root.AddNew(name, v, dst)
+	case "rm":
+		err = root.Delete(names)
+	case "cp":
+		err = root.Copy(names, split(args[2]))
+	case "mv":
+		err = root.Move(names, split(args[2]))
+	}
+	if err != nil {
+		return err
+	}
+
+	return root.Save()
+}
+
+func main() {
+	if (len(os.Args) < 2) || (os.Args[1] == "-h") || (os.Args[1] == "help") {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	err := run(os.Args[1], os.Args[2:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "(Error) %v\n", err)
+		os.Exit(1)
+	}
+}
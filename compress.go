@@ -0,0 +1,127 @@
+package tree
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// CompressNone as Tree.CompressLevel stores without compression, 0 is the default level.
+const CompressNone = -256
+
+// Compressor is selected by the file extension on save and by Magic on open.
+// zstd is not in the standard library, register it with e.g.
+// github.com/klauspost/compress/zstd to handle ".zst" files.
+type Compressor struct {
+	Ext    string
+	Magic  []byte
+	Reader func(r io.Reader) (io.ReadCloser, error)
+	Writer func(w io.Writer, level int) (io.WriteCloser, error)
+}
+
+var (
+	compressMu  sync.RWMutex
+	compressors = []Compressor{
+		{
+			Ext:   ".gz",
+			Magic: []byte{0x1f, 0x8b},
+			Reader: func(r io.Reader) (io.ReadCloser, error) {
+				return gzip.NewReader(r)
+			},
+			Writer: func(w io.Writer, level int) (io.WriteCloser, error) {
+				switch level {
+				case 0:
+					level = gzip.DefaultCompression
+				case CompressNone:
+					level = gzip.NoCompression
+				}
+				return gzip.NewWriterLevel(w, level)
+			},
+		},
+		{
+			Ext:   ".zst",
+			Magic: []byte{0x28, 0xb5, 0x2f, 0xfd},
+		},
+	}
+)
+
+func RegisterCompressor(c Compressor) {
+	compressMu.Lock()
+	defer compressMu.Unlock()
+
+	for i := range compressors {
+		if compressors[i].Ext == c.Ext {
+			compressors[i] = c
+			return
+		}
+	}
+	compressors = append(compressors, c)
+}
+
+// findCompressor looks up by the extension of fileName, or by the magic bytes of head.
+func findCompressor(fileName string, head []byte) (*Compressor, error) {
+	compressMu.RLock()
+	defer compressMu.RUnlock()
+
+	ext := strings.ToLower(filepath.Ext(fileName))
+	for i := range compressors {
+		c := compressors[i]
+
+		if fileName != "" {
+			if ext != c.Ext {
+				continue
+			}
+		} else if !bytes.HasPrefix(head, c.Magic) {
+			continue
+		}
+
+		if (c.Reader == nil) || (c.Writer == nil) {
+			return nil, fmt.Errorf("compression is not registered: %v", c.Ext)
+		}
+		return &c, nil
+	}
+
+	return nil, nil
+}
+
+func compress(fileName string, buf []byte, level int) ([]byte, error) {
+	c, err := findCompressor(fileName, nil)
+	if (err != nil) || (c == nil) {
+		return buf, err
+	}
+
+	var b bytes.Buffer
+	w, err := c.Writer(&b, level)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = w.Write(buf)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+func decompress(data []byte) ([]byte, error) {
+	c, err := findCompressor("", data)
+	if (err != nil) || (c == nil) {
+		return data, err
+	}
+
+	r, err := c.Reader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
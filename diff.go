@@ -0,0 +1,174 @@
+package tree
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type diffNode struct {
+	path []string
+	tw   *twig
+}
+
+func (tw *twig) equal(o *twig) bool {
+	if (tw.Name != o.Name) || !tw.same(o) || (len(tw.Childs) != len(o.Childs)) {
+		return false
+	}
+
+	for i := range tw.Childs {
+		if !tw.Childs[i].equal(&o.Childs[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func diffTwig(a *twig, b *twig, names []string, changes *[]Change, added *[]diffNode, removed *[]diffNode) {
+	for i := range a.Childs {
+		atw := &a.Childs[i]
+		path := appendName(names, atw.Name)
+
+		j := b.child(atw.Name)
+		if j < 0 {
+			*removed = append(*removed, diffNode{path: path, tw: atw})
+			continue
+		}
+		btw := &b.Childs[j]
+
+		if !atw.same(btw) {
+			*changes = append(*changes, Change{
+				Op: "replace", Path: path,
+				Old: atw.get(), OldKind: atw.Kind,
+				New: btw.get(), NewKind: btw.Kind,
+			})
+		}
+		diffTwig(atw, btw, path, changes, added, removed)
+	}
+
+	for j := range b.Childs {
+		btw := &b.Childs[j]
+
+		if a.child(btw.Name) < 0 {
+			path := appendName(names, btw.Name)
+			*added = append(*added, diffNode{path: path, tw: btw})
+		}
+	}
+}
+
+func Diff(a *Tree, b *Tree) ([]Change, error) {
+	var changes []Change
+	var added []diffNode
+	var removed []diffNode
+
+	if (a == nil) || (a.Base == nil) || (b == nil) || (b.Base == nil) {
+		return nil, fmt.Errorf("root is NULL")
+	}
+
+	err := a.Base.loadAll()
+	if err == nil {
+		err = b.Base.loadAll()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	diffTwig(a.Base, b.Base, nil, &changes, &added, &removed)
+
+	// a removed node that reappears unchanged at another path was moved
+	var result []Change
+	for i := range removed {
+		r := removed[i]
+
+		sw := true
+		for j := range added {
+			if (added[j].tw != nil) && r.tw.equal(added[j].tw) {
+				result = append(result, Change{Op: "move", Path: added[j].path, From: r.path})
+				added[j].tw = nil
+				sw = false
+				break
+			}
+		}
+
+		if sw {
+			result = append(result, Change{Op: "remove", Path: r.path, Old: r.tw.get(), OldKind: r.tw.Kind})
+		}
+	}
+
+	for i := range added {
+		if added[i].tw != nil {
+			tw := added[i].tw
+			result = append(result, Change{Op: "add", Path: added[i].path, New: tw.get(), NewKind: tw.Kind})
+		}
+	}
+
+	return append(result, changes...), nil
+}
+
+func (root *Tree) ReloadDiff() ([]Change, error) {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	if root.Base == nil {
+		return nil, fmt.Errorf("root is NULL")
+	}
+
+	base, err := root.Base.clone()
+	if err != nil {
+		return nil, err
+	}
+	old := &Tree{Base: &base}
+
+	err = root.reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return Diff(old, root)
+}
+
+func diffValue(value any, kind string) string {
+	if kind == "" {
+		return "null"
+	}
+
+	var tw twig
+	tw.setKind(value)
+	if kind == "string" {
+		return fmt.Sprintf("%q (%v)", tw.get("string"), kind)
+	}
+	return fmt.Sprintf("%v (%v)", tw.get("string"), kind)
+}
+
+func DiffText(changes []Change) string {
+	var sb strings.Builder
+
+	for i := range changes {
+		c := changes[i]
+		path := strings.Join(c.Path, "/")
+
+		switch c.Op {
+		case "add":
+			fmt.Fprintf(&sb, "+ %v = %v\n", path, diffValue(c.New, c.NewKind))
+		case "remove":
+			fmt.Fprintf(&sb, "- %v = %v\n", path, diffValue(c.Old, c.OldKind))
+		case "replace":
+			fmt.Fprintf(&sb, "~ %v: %v -> %v\n", path, diffValue(c.Old, c.OldKind), diffValue(c.New, c.NewKind))
+		case "move":
+			fmt.Fprintf(&sb, "> %v -> %v\n", strings.Join(c.From, "/"), path)
+		}
+	}
+
+	return sb.String()
+}
+
+func DiffJSON(changes []Change, indent string) ([]byte, error) {
+	if changes == nil {
+		changes = []Change{}
+	}
+
+	if len(indent) == 0 {
+		return json.Marshal(changes)
+	}
+	return json.MarshalIndent(changes, "", indent)
+}
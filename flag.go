@@ -0,0 +1,103 @@
+package tree
+
+import (
+	"flag"
+	"strings"
+)
+
+// Flag binds a tree path to the flag package.
+// It also has the Type() method, so it can be passed to pflag.FlagSet.Var.
+type Flag struct {
+	root  *Tree
+	names []string
+}
+
+func (root *Tree) FlagValue(src []string) (*Flag, error) {
+	_, err := root.Find(src)
+	if err != nil {
+		return nil, err
+	}
+
+	names := append([]string{}, src...)
+	return &Flag{root: root, names: names}, nil
+}
+
+func (f *Flag) String() string {
+	if (f == nil) || (f.root == nil) {
+		return ""
+	}
+
+	tw, err := f.root.Find(f.names)
+	if err != nil {
+		return ""
+	}
+
+	if tw.Kind == "secret" {
+		return secretMask
+	}
+
+	v := tw.get("string")
+	if v == nil {
+		return ""
+	}
+	return v.(string)
+}
+
+func (f *Flag) Set(s string) error {
+	tw, err := f.root.Find(f.names)
+	if err != nil {
+		return err
+	}
+
+	if tw.Kind == "secret" {
+		return f.root.SetSecret(s, f.names)
+	}
+
+	value, err := parseKind(tw.Kind, s)
+	if err != nil {
+		return err
+	}
+
+	return f.root.SetValue(value, f.names)
+}
+
+func (f *Flag) Type() string {
+	if (f == nil) || (f.root == nil) {
+		return "string"
+	}
+
+	tw, err := f.root.Find(f.names)
+	if (err != nil) || (tw.Kind == "") {
+		return "string"
+	}
+	return tw.Kind
+}
+
+func (f *Flag) IsBoolFlag() bool {
+	return f.Type() == "bool"
+}
+
+func (root *Tree) BindFlagsFunc(src []string, fn func(v *Flag, name string, usage string)) error {
+	return root.Walk(src, func(path []string, node Node) error {
+		if (len(path) == len(src)) || (node.Kind() == "") {
+			return nil
+		}
+
+		usage := node.Desc()
+		if usage == "" {
+			usage = node.Kind() + " value of " + strings.Join(path, "/")
+		}
+		fn(&Flag{root: root, names: path}, strings.Join(path, "."), usage)
+		return nil
+	})
+}
+
+func (root *Tree) BindFlags(fs *flag.FlagSet, src []string) error {
+	if fs == nil {
+		fs = flag.CommandLine
+	}
+
+	return root.BindFlagsFunc(src, func(v *Flag, name string, usage string) {
+		fs.Var(v, name, usage)
+	})
+}
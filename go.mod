@@ -0,0 +1,3 @@
+module github.com/mususu247/tree
+
+go 1.23
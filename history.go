@@ -0,0 +1,365 @@
+package tree
+
+import (
+	"fmt"
+)
+
+// action is one step of the history, seq identifies it.
+type action struct {
+	undo func(root *Tree) error
+	redo func(root *Tree) error
+	seq  uint64
+}
+
+type history struct {
+	done   []action
+	undone []action
+	depth  int
+	off    bool
+	group  int
+	batch  []action
+	seq    uint64
+	base   uint64
+	saved  uint64
+	marks  map[string]uint64
+}
+
+func (root *Tree) EnableHistory(depth int) {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	root.hist = &history{depth: depth, marks: make(map[string]uint64)}
+}
+
+func (root *Tree) DisableHistory() {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	root.hist = nil
+}
+
+func (h *history) reset() {
+	h.done = nil
+	h.undone = nil
+	h.batch = nil
+	h.group = 0
+	h.base = 0
+	h.saved = 0
+	h.marks = make(map[string]uint64)
+}
+
+// record is called by every mutator after the change is made.
+// patch describes the change for the journal, without it the journal is compacted.
+// When the journal can not be written the change is undone and the error returned.
+func (root *Tree) record(undo func(root *Tree) error, redo func(root *Tree) error, patch ...Operation) error {
+	root.version++
+
+	h := root.hist
+	pushed := (h != nil) && !h.off
+	var undone []action
+	if pushed {
+		undone = h.undone
+		a := action{undo: undo, redo: redo}
+		if h.group > 0 {
+			h.batch = append(h.batch, a)
+		} else {
+			h.push(a)
+		}
+	}
+
+	err := root.journal(patch...)
+	if err != nil {
+		if pushed && (h.group > 0) {
+			h.batch = h.batch[:len(h.batch)-1]
+		} else if pushed {
+			h.done = h.done[:len(h.done)-1]
+			h.undone = undone
+		}
+		root.rollback(undo)
+		return err
+	}
+
+	if !root.replaying {
+		root.notify(patch...)
+	}
+	return nil
+}
+
+// rollback reverts a change that is not in the journal, without journal and history.
+func (root *Tree) rollback(undo func(root *Tree) error) {
+	if undo == nil {
+		return
+	}
+
+	replaying := root.replaying
+	root.replaying = true
+	defer func() { root.replaying = replaying }()
+
+	if h := root.hist; h != nil {
+		off := h.off
+		h.off = true
+		defer func() { h.off = off }()
+	}
+
+	undo(root)
+}
+
+func (h *history) push(a action) {
+	h.seq++
+	a.seq = h.seq
+	h.done = append(h.done, a)
+	h.undone = nil
+
+	if (h.depth > 0) && (len(h.done) > h.depth) {
+		n := len(h.done) - h.depth
+		h.base = h.done[n-1].seq
+		h.done = append([]action{}, h.done[n:]...)
+
+		for k := range h.marks {
+			if h.marks[k] < h.base {
+				delete(h.marks, k)
+			}
+		}
+	}
+}
+
+func (root *Tree) beginGroup() {
+	if root.hist != nil {
+		root.hist.group++
+	}
+}
+
+func (root *Tree) endGroup() {
+	h := root.hist
+	if h == nil {
+		return
+	}
+
+	h.group--
+	if (h.group > 0) || (len(h.batch) == 0) {
+		return
+	}
+
+	batch := h.batch
+	h.batch = nil
+	h.push(action{
+		undo: func(root *Tree) error {
+			for i := len(batch) - 1; i >= 0; i-- {
+				err := batch[i].undo(root)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		redo: func(root *Tree) error {
+			for i := range batch {
+				err := batch[i].redo(root)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
+
+// checkpoint copies the tree before a mutation that is hard to invert,
+// recordSince stores it together with the result as one step.
+// With a journal the copy is kept as well, to undo the change when the journal fails.
+func (root *Tree) checkpoint() (*twig, error) {
+	if root.Base == nil {
+		return nil, nil
+	}
+	if ((root.hist == nil) || root.hist.off) && !root.Journal {
+		return nil, nil
+	}
+
+	before, err := root.Base.clone()
+	if err != nil {
+		return nil, err
+	}
+	return &before, nil
+}
+
+func (root *Tree) recordSince(before *twig) error {
+	if (before == nil) || (root.Base == nil) {
+		return root.record(nil, nil)
+	}
+
+	after, err := root.Base.clone()
+	if err != nil {
+		return err
+	}
+
+	restore := func(w *twig) func(root *Tree) error {
+		return func(root *Tree) error {
+			base, err := w.clone()
+			if err != nil {
+				return err
+			}
+			root.Base = &base
+			return nil
+		}
+	}
+	return root.record(restore(before), restore(&after))
+}
+
+func (root *Tree) forget() {
+	h := root.hist
+	if (h != nil) && !h.off && (len(h.done) != 0) {
+		h.done = h.done[:len(h.done)-1]
+	}
+}
+
+func (root *Tree) CanUndo() bool {
+	return (root.hist != nil) && (len(root.hist.done) != 0)
+}
+
+func (root *Tree) CanRedo() bool {
+	return (root.hist != nil) && (len(root.hist.undone) != 0)
+}
+
+func (root *Tree) Undo() error {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	return root.undo()
+}
+
+func (root *Tree) undo() error {
+	if !root.CanUndo() {
+		return fmt.Errorf("nothing to undo")
+	}
+
+	h := root.hist
+	a := h.done[len(h.done)-1]
+
+	// the step is journaled by compaction, it is undone when that fails
+	h.off = true
+	root.replaying = true
+	err := a.undo(root)
+	root.replaying = false
+	if err == nil {
+		err = root.record(nil, nil)
+		if err != nil {
+			root.rollback(a.redo)
+		}
+	}
+	h.off = false
+	if err != nil {
+		return err
+	}
+
+	h.done = h.done[:len(h.done)-1]
+	h.undone = append(h.undone, a)
+	return nil
+}
+
+func (root *Tree) Redo() error {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	return root.redo()
+}
+
+func (root *Tree) redo() error {
+	if !root.CanRedo() {
+		return fmt.Errorf("nothing to redo")
+	}
+
+	h := root.hist
+	a := h.undone[len(h.undone)-1]
+
+	h.off = true
+	root.replaying = true
+	err := a.redo(root)
+	root.replaying = false
+	if err == nil {
+		err = root.record(nil, nil)
+		if err != nil {
+			root.rollback(a.undo)
+		}
+	}
+	h.off = false
+	if err != nil {
+		return err
+	}
+
+	h.undone = h.undone[:len(h.undone)-1]
+	h.done = append(h.done, a)
+	return nil
+}
+
+func (root *Tree) Mark(name string) error {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	if root.hist == nil {
+		return fmt.Errorf("history is disabled")
+	}
+
+	root.hist.marks[name] = root.hist.top()
+	return nil
+}
+
+func (root *Tree) UndoTo(name string) error {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	if root.hist == nil {
+		return fmt.Errorf("history is disabled")
+	}
+
+	h := root.hist
+	seq, ok := h.marks[name]
+	if !ok {
+		return fmt.Errorf("not found mark: %v", name)
+	}
+
+	// the mark is the base or a done step, otherwise it was undone or replaced by a new step
+	found := (seq == h.base)
+	for i := range h.done {
+		found = found || (h.done[i].seq == seq)
+	}
+	if !found {
+		for i := range h.undone {
+			if h.undone[i].seq == seq {
+				return fmt.Errorf("mark is undone: %v", name)
+			}
+		}
+		return fmt.Errorf("mark is lost: %v", name)
+	}
+
+	for h.top() != seq {
+		err := root.undo()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// top is the seq of the last done action, base is the one of the last dropped by depth.
+func (h *history) top() uint64 {
+	if len(h.done) == 0 {
+		return h.base
+	}
+	return h.done[len(h.done)-1].seq
+}
+
+func (root *Tree) markSaved() {
+	root.saved = root.version
+	if root.hist != nil {
+		root.hist.saved = root.hist.top()
+	}
+}
+
+// Modified reports whether the tree differs from the saved file,
+// undoing back to the saved step makes it unmodified again.
+func (root *Tree) Modified() bool {
+	if root.hist != nil {
+		return (root.hist.saved != root.hist.top()) || (len(root.hist.batch) != 0)
+	}
+	return root.saved != root.version
+}
@@ -0,0 +1,48 @@
+package tree
+
+import (
+	"testing"
+)
+
+func TestUndoTo(t *testing.T) {
+	root := &Tree{Base: &twig{Name: "root"}}
+	root.EnableHistory(3)
+
+	root.AddNew("a", int64(1), nil)
+	root.Mark("a")
+	root.AddNew("b", int64(2), nil)
+	root.AddNew("c", int64(3), nil)
+
+	err := root.UndoTo("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, _ := root.List(nil)
+	if len(list) != 1 {
+		t.Fatalf("%v", list)
+	}
+
+	// a new step after undoing past the mark loses it
+	root.Mark("b")
+	root.AddNew("b", int64(2), nil)
+	root.Mark("c")
+	root.Undo()
+	root.Undo()
+	root.AddNew("d", int64(4), nil)
+	root.AddNew("x", nil, nil)
+
+	err = root.UndoTo("c")
+	if err == nil {
+		t.Fatal("lost mark is undone to")
+	}
+
+	// trimmed by depth
+	root.Mark("d")
+	for _, name := range []string{"e", "f", "g", "h"} {
+		root.AddNew(name, nil, nil)
+	}
+	err = root.UndoTo("d")
+	if err == nil {
+		t.Fatal("trimmed mark is undone to")
+	}
+}
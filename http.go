@@ -0,0 +1,270 @@
+package tree
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type nodeView struct {
+	Name   string     `json:"name"`
+	Kind   string     `json:"kind"`
+	Value  any        `json:"value"`
+	Desc   string     `json:"desc,omitempty"`
+	Childs []nodeView `json:"childs,omitempty"`
+}
+
+type valueRequest struct {
+	Name  string `json:"name"`
+	Value any    `json:"value"`
+	Kind  string `json:"kind"`
+}
+
+type handler struct {
+	root *Tree
+}
+
+// Watch calls fn after every change of the tree, until cancel is called.
+// fn is called while the tree is changed, it must not block or change the tree.
+func (root *Tree) Watch(fn func(o Operation)) (cancel func()) {
+	root.watchMu.Lock()
+	defer root.watchMu.Unlock()
+
+	if root.watchers == nil {
+		root.watchers = make(map[int]func(o Operation))
+	}
+	root.watchID++
+	id := root.watchID
+	root.watchers[id] = fn
+
+	return func() {
+		root.watchMu.Lock()
+		defer root.watchMu.Unlock()
+		delete(root.watchers, id)
+	}
+}
+
+// notify reports the change, a change without an operation is sent as "reload".
+func (root *Tree) notify(patch ...Operation) {
+	root.watchMu.Lock()
+	defer root.watchMu.Unlock()
+
+	if len(root.watchers) == 0 {
+		return
+	}
+	if len(patch) == 0 {
+		patch = []Operation{{Op: "reload", Path: []string{}}}
+	}
+
+	for i := range patch {
+		o := patch[i]
+		if root.masked(o) {
+			o.Value = secretMask
+		}
+		for _, fn := range root.watchers {
+			fn(o)
+		}
+	}
+}
+
+// masked reports whether the value of o is hidden from watchers, like GET hides it.
+func (root *Tree) masked(o Operation) bool {
+	if o.Kind == "secret" {
+		return true
+	}
+	if ((o.Op != "add") && (o.Op != "replace")) || (len(o.Path) == 0) {
+		return false
+	}
+
+	tw := &twig{Name: o.Path[len(o.Path)-1], Kind: o.Kind}
+	return root.redacted(o.Path, tw) || root.hidden(o.Path)
+}
+
+func (root *Tree) view(tw *twig, names []string, hidden bool) nodeView {
+	v := nodeView{Name: tw.Name, Kind: tw.Kind, Value: tw.get(), Desc: tw.Desc}
+	hidden = hidden || root.redacted(names, tw)
+	if (tw.Kind != "") && hidden {
+		v.Value = secretMask
+	}
+
+	for i := range tw.Childs {
+		ctw := &tw.Childs[i]
+		v.Childs = append(v.Childs, root.view(ctw, appendName(names, ctw.Name), hidden))
+	}
+	return v
+}
+
+// Handler serves the tree over HTTP, the URL path is the tree path:
+// GET returns the subtree, PUT sets {"value","kind"}, POST adds {"name","value","kind"},
+// DELETE removes. ETag and If-Match guard against lost updates,
+// GET with "Accept: text/event-stream" streams the changes.
+func (root *Tree) Handler() http.Handler {
+	return &handler{root: root}
+}
+
+func (h *handler) etag() string {
+	return `"` + strconv.FormatUint(h.root.version, 10) + `"`
+}
+
+func httpError(w http.ResponseWriter, code int, err error) {
+	http.Error(w, err.Error(), code)
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var names []string
+
+	path := strings.Trim(r.URL.Path, "/")
+	if path != "" {
+		names = strings.Split(path, "/")
+	}
+
+	if (r.Method == http.MethodGet) && strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		h.events(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		h.root.mu.RLock()
+		defer h.root.mu.RUnlock()
+
+		tw, err := h.root.Find(names)
+		if err == nil {
+			err = tw.loadAll()
+		}
+		if err != nil {
+			httpError(w, http.StatusNotFound, err)
+			return
+		}
+
+		w.Header().Set("ETag", h.etag())
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(h.root.view(tw, names, h.root.hidden(names)))
+		return
+	case http.MethodPut, http.MethodPost, http.MethodDelete:
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT, POST, DELETE")
+		httpError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed: %v", r.Method))
+		return
+	}
+
+	var req valueRequest
+	if r.Method != http.MethodDelete {
+		dec := json.NewDecoder(r.Body)
+		dec.UseNumber()
+		err := dec.Decode(&req)
+		if err != nil {
+			httpError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	h.root.mu.Lock()
+	defer h.root.mu.Unlock()
+
+	if m := r.Header.Get("If-Match"); (m != "") && (m != "*") && (m != h.etag()) {
+		httpError(w, http.StatusPreconditionFailed, fmt.Errorf("tree changed: %v", h.etag()))
+		return
+	}
+
+	_, err := h.root.Find(names)
+	if err != nil {
+		httpError(w, http.StatusNotFound, err)
+		return
+	}
+
+	code := http.StatusOK
+	switch r.Method {
+	case http.MethodPut:
+		err = h.set(names, req)
+	case http.MethodPost:
+		if req.Name == "" {
+			err = fmt.Errorf("name is blank")
+			break
+		}
+		err = h.add(names, req)
+		code = http.StatusCreated
+	case http.MethodDelete:
+		if len(names) == 0 {
+			err = fmt.Errorf("can not delete root")
+			break
+		}
+		err = h.root.deleteNode(names)
+	}
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	w.Header().Set("ETag", h.etag())
+	w.WriteHeader(code)
+}
+
+func (h *handler) set(names []string, req valueRequest) error {
+	if req.Kind == "secret" {
+		s, ok := req.Value.(string)
+		if !ok {
+			return fmt.Errorf("secret is not string")
+		}
+		return h.root.setSecret(s, names)
+	}
+
+	o := Operation{Op: "replace", Path: names, Value: req.Value, Kind: req.Kind}
+	return h.root.applyOp(o)
+}
+
+func (h *handler) add(names []string, req valueRequest) error {
+	if req.Kind == "secret" {
+		s, ok := req.Value.(string)
+		if !ok {
+			return fmt.Errorf("secret is not string")
+		}
+		return h.root.addSecret(req.Name, s, names)
+	}
+
+	o := Operation{Value: req.Value, Kind: req.Kind}
+	value, err := o.value()
+	if err != nil {
+		return err
+	}
+	return h.root.addNew(req.Name, value, names)
+}
+
+func (h *handler) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		httpError(w, http.StatusInternalServerError, fmt.Errorf("streaming is not supported"))
+		return
+	}
+
+	ch := make(chan Operation, 64)
+	cancel := h.root.Watch(func(o Operation) {
+		select {
+		case ch <- o:
+		default:
+			// a slow client misses events rather than blocking the tree
+		}
+	})
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case o := <-ch:
+			buf, err := json.Marshal(o)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %v\ndata: %s\n\n", o.Op, buf)
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package tree
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func httpTree() *Tree {
+	root := &Tree{Base: &twig{Name: "root"}}
+	root.AddNew("app", nil, nil)
+	root.AddNew("port", int64(8080), []string{"app"})
+	root.AddNew("name", "web", []string{"app"})
+	return root
+}
+
+func request(t *testing.T, method string, url string, body string, header ...string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { res.Body.Close() })
+	return res
+}
+
+func TestHandlerGet(t *testing.T) {
+	srv := httptest.NewServer(httpTree().Handler())
+	defer srv.Close()
+
+	res := request(t, http.MethodGet, srv.URL+"/app", "")
+	if res.StatusCode != http.StatusOK {
+		t.Fatal(res.Status)
+	}
+	if res.Header.Get("ETag") == "" {
+		t.Fatal("no etag")
+	}
+
+	var v nodeView
+	err := json.NewDecoder(res.Body).Decode(&v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (v.Name != "app") || (len(v.Childs) != 2) || (v.Childs[1].Value != "web") {
+		t.Fatalf("%+v", v)
+	}
+
+	res = request(t, http.MethodGet, srv.URL+"/nothing", "")
+	if res.StatusCode != http.StatusNotFound {
+		t.Fatal(res.Status)
+	}
+}
+
+func TestHandlerPut(t *testing.T) {
+	root := httpTree()
+	srv := httptest.NewServer(root.Handler())
+	defer srv.Close()
+
+	etag := request(t, http.MethodGet, srv.URL+"/app", "").Header.Get("ETag")
+
+	res := request(t, http.MethodPut, srv.URL+"/app/port", `{"value": 9090}`, "If-Match", etag)
+	if res.StatusCode != http.StatusOK {
+		t.Fatal(res.Status)
+	}
+	if res.Header.Get("ETag") == etag {
+		t.Fatal("etag not changed")
+	}
+	v, err := root.GetValueInt([]string{"app", "port"})
+	if (err != nil) || (v != 9090) {
+		t.Fatal(v, err)
+	}
+
+	// the tree changed since etag was read
+	res = request(t, http.MethodPut, srv.URL+"/app/port", `{"value": 7070}`, "If-Match", etag)
+	if res.StatusCode != http.StatusPreconditionFailed {
+		t.Fatal(res.Status)
+	}
+	v, _ = root.GetValueInt([]string{"app", "port"})
+	if v != 9090 {
+		t.Fatal(v)
+	}
+}
+
+func TestHandlerPostDelete(t *testing.T) {
+	root := httpTree()
+	srv := httptest.NewServer(root.Handler())
+	defer srv.Close()
+
+	res := request(t, http.MethodPost, srv.URL+"/app", `{"name": "debug", "value": true}`)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatal(res.Status)
+	}
+	b, err := root.GetValueBool([]string{"app", "debug"})
+	if (err != nil) || !b {
+		t.Fatal(b, err)
+	}
+
+	res = request(t, http.MethodPost, srv.URL+"/app", `{"name": "debug", "value": false}`)
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatal(res.Status)
+	}
+
+	res = request(t, http.MethodDelete, srv.URL+"/app/debug", "")
+	if res.StatusCode != http.StatusOK {
+		t.Fatal(res.Status)
+	}
+	_, err = root.Find([]string{"app", "debug"})
+	if err == nil {
+		t.Fatal("not deleted")
+	}
+}
+
+func TestHandlerEvents(t *testing.T) {
+	root := httpTree()
+	srv := httptest.NewServer(root.Handler())
+	defer srv.Close()
+	defer srv.CloseClientConnections()
+
+	res := request(t, http.MethodGet, srv.URL+"/", "", "Accept", "text/event-stream")
+	if res.StatusCode != http.StatusOK {
+		t.Fatal(res.Status)
+	}
+
+	err := root.SetValue("api", []string{"app", "name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := make(chan string)
+	go func() {
+		sc := bufio.NewScanner(res.Body)
+		for sc.Scan() {
+			lines <- sc.Text()
+		}
+		close(lines)
+	}()
+
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatal("stream closed")
+			}
+			if !strings.HasPrefix(line, "data: ") {
+				continue
+			}
+
+			var o Operation
+			err = json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (o.Op != "replace") || (o.Value != "api") {
+				t.Fatalf("%+v", o)
+			}
+			return
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event")
+		}
+	}
+}
+
+func TestNotifyRedact(t *testing.T) {
+	root := httpTree()
+	root.Redact = []string{"app/name", "db"}
+	root.AddNew("db", nil, nil)
+
+	var got []Operation
+	cancel := root.Watch(func(o Operation) {
+		got = append(got, o)
+	})
+	defer cancel()
+
+	root.SetValue("topsecret", []string{"app", "name"})
+	root.AddNew("conn", nil, []string{"db"})
+	root.AddNew("pw", "hunter2", []string{"db", "conn"})
+	root.SetValue(int64(1), []string{"app", "port"})
+
+	if len(got) != 4 {
+		t.Fatalf("%+v", got)
+	}
+	for i, o := range got[:3] {
+		if (o.Value != nil) && (o.Value != secretMask) {
+			t.Fatalf("%v: %+v", i, o)
+		}
+	}
+	if got[3].Value != int64(1) {
+		t.Fatalf("%+v", got[3])
+	}
+}
@@ -0,0 +1,327 @@
+package tree
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Include is the value of an include node, a file name or a glob relative to
+// the file holding the node. On Open the childs of the node are read from the file,
+// for a glob every file becomes a child named by the file name without extension.
+// Save writes the childs back to the files they came from.
+type Include string
+
+// include is the provenance of an expanded node, file is the file of its childs.
+// For a glob, members are the files read or written last, Save removes the ones
+// whose node was deleted or renamed.
+type include struct {
+	file    string
+	members []string
+}
+
+func isGlob(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
+
+func includeName(file string) string {
+	name := filepath.Base(file)
+	ext := filepath.Ext(name)
+	if c, err := findCompressor(name, nil); (c != nil) || (err != nil) {
+		name = strings.TrimSuffix(name, ext)
+		ext = filepath.Ext(name)
+	}
+	return strings.TrimSuffix(name, ext)
+}
+
+// globExt is the extension of new files of a glob, e.g. ".json" for "conf.d/*.json".
+func globExt(pattern string) string {
+	name := filepath.Base(pattern)
+	if i := strings.LastIndexAny(name, "*?]"); i >= 0 {
+		return name[i+1:]
+	}
+	return ""
+}
+
+// includeFile is the absolute file or glob of an include node in a file of dir.
+func includeFile(tw *twig, dir string) string {
+	if tw.inc != nil {
+		return tw.inc.file
+	}
+
+	s, _ := tw.Value.(string)
+	file := filepath.FromSlash(s)
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(dir, file)
+	}
+	return file
+}
+
+// expand reads the include nodes below tw, dir is the directory of the file holding tw.
+// stack holds the files being read, optional allows missing files.
+func (root *Tree) expand(tw *twig, dir string, stack map[string]bool, optional bool) error {
+	for i := range tw.Childs {
+		c := &tw.Childs[i]
+
+		var err error
+		if c.Kind == "include" {
+			err = root.expandInclude(c, dir, stack, optional)
+		} else {
+			err = root.expand(c, dir, stack, optional)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (root *Tree) expandInclude(tw *twig, dir string, stack map[string]bool, optional bool) error {
+	file := includeFile(tw, dir)
+	tw.inc = &include{file: file}
+	tw.Childs = nil
+	tw.lazy = nil
+	tw.unindex()
+
+	if !isGlob(file) {
+		return root.includeFile(tw, file, stack, optional)
+	}
+
+	files, err := filepath.Glob(file)
+	if err != nil {
+		return err
+	}
+	tw.inc.members = files
+	for _, f := range files {
+		w := twig{Name: includeName(f), inc: &include{file: f}}
+		if tw.child(w.Name) >= 0 {
+			return fmt.Errorf("duplicate name. %v", f)
+		}
+
+		err = root.includeFile(&w, f, stack, false)
+		if err != nil {
+			return err
+		}
+		tw.add(w)
+	}
+	return nil
+}
+
+func (root *Tree) includeFile(tw *twig, file string, stack map[string]bool, optional bool) error {
+	if stack[file] {
+		return fmt.Errorf("include cycle: %v", file)
+	}
+	stack[file] = true
+	defer delete(stack, file)
+
+	base, err := root.read(file, true)
+	if optional && errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("include %v: %v", file, err)
+	}
+
+	err = root.expand(base, filepath.Dir(file), stack, optional)
+	if err != nil {
+		return err
+	}
+
+	tw.Childs = base.Childs
+	tw.unindex()
+	return nil
+}
+
+// AddInclude adds an include node and reads its files, a missing file is created by Save.
+func (root *Tree) AddInclude(name string, pattern string, dst []string) error {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	owner, err := root.Owner(dst)
+	if err != nil {
+		return err
+	}
+
+	err = root.addNew(name, Include(pattern), dst)
+	if err != nil {
+		return err
+	}
+
+	tw, _, err := root.resolve(appendName(dst, name), false, nil)
+	if err != nil {
+		return err
+	}
+	return root.expandInclude(tw, filepath.Dir(owner), map[string]bool{owner: true}, true)
+}
+
+// Owner returns the file that holds the node src.
+func (root *Tree) Owner(src []string) (string, error) {
+	if root.Base == nil {
+		return "", fmt.Errorf("root is NULL: %v", src)
+	}
+
+	owner := root.fileName
+	glob := ""
+	r := root.Base
+	for i := range src {
+		err := r.load()
+		if err != nil {
+			return "", err
+		}
+
+		j := r.child(src[i])
+		if j < 0 {
+			return "", fmt.Errorf("not found: %v", src[i])
+		}
+		r = &r.Childs[j]
+
+		// the childs of an include node, and each file of a glob, belong to the included file
+		if glob != "" {
+			owner = globFile(r, glob)
+			glob = ""
+		}
+		if (r.Kind == "include") && (i < len(src)-1) {
+			file := includeFile(r, filepath.Dir(owner))
+			if isGlob(file) {
+				glob = file
+			} else {
+				owner = file
+			}
+		}
+	}
+	return owner, nil
+}
+
+// globFile is the file of tw, a child of an include node of the glob pattern.
+// A renamed child keeps the extension of its file.
+func globFile(tw *twig, pattern string) string {
+	if tw.inc == nil {
+		return filepath.Join(filepath.Dir(pattern), tw.Name+globExt(pattern))
+	}
+
+	file := tw.inc.file
+	name := includeName(file)
+	if name == tw.Name {
+		return file
+	}
+	ext := strings.TrimPrefix(filepath.Base(file), name)
+	return filepath.Join(filepath.Dir(file), tw.Name+ext)
+}
+
+// stripIncludes copies tw without the childs of expanded include nodes, they are in their own files.
+func stripIncludes(tw *twig) twig {
+	w := twig{Name: tw.Name, Kind: tw.Kind, Value: tw.Value, Desc: tw.Desc, inc: tw.inc}
+	if (tw.Kind == "include") && (tw.inc != nil) {
+		return w
+	}
+
+	for i := range tw.Childs {
+		w.Childs = append(w.Childs, stripIncludes(&tw.Childs[i]))
+	}
+	return w
+}
+
+func hasIncludes(tw *twig) bool {
+	if (tw.Kind == "include") && (tw.inc != nil) {
+		return true
+	}
+
+	for i := range tw.Childs {
+		if hasIncludes(&tw.Childs[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// storeIncludes writes the childs of the expanded include nodes below tw to their files.
+func (root *Tree) storeIncludes(tw *twig, dir string) error {
+	for i := range tw.Childs {
+		c := &tw.Childs[i]
+
+		if (c.Kind != "include") || (c.inc == nil) {
+			err := root.storeIncludes(c, dir)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		file := includeFile(c, dir)
+		if !isGlob(file) {
+			err := root.storeInclude(c, file)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		var members []string
+		stored := make(map[string]bool, len(c.Childs))
+		for j := range c.Childs {
+			g := &c.Childs[j]
+			f := globFile(g, file)
+
+			err := root.storeInclude(g, f)
+			if err != nil {
+				return err
+			}
+			g.inc = &include{file: f}
+			members = append(members, f)
+			stored[f] = true
+		}
+
+		// files of deleted or renamed childs
+		for _, f := range c.inc.members {
+			if stored[f] {
+				continue
+			}
+			err := removeFile(f)
+			if err != nil {
+				return fmt.Errorf("include %v: %v", f, err)
+			}
+		}
+		c.inc = &include{file: file, members: members}
+	}
+	return nil
+}
+
+// removeFile removes a file with its sidecars.
+func removeFile(fileName string) error {
+	for _, f := range withSidecars(fileName) {
+		err := os.Remove(f)
+		if (err != nil) && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (root *Tree) storeInclude(tw *twig, file string) error {
+	base := twig{Name: "root", Childs: tw.Childs}
+
+	var buf []byte
+	var err error
+	if len(root.Indent) == 0 {
+		buf, err = json.Marshal(stripIncludes(&base))
+	} else {
+		buf, err = json.MarshalIndent(stripIncludes(&base), "", root.Indent)
+	}
+	if err != nil {
+		return err
+	}
+
+	buf, err = root.seal(file, buf)
+	if err == nil {
+		err = root.store(file, buf)
+	}
+	if err != nil {
+		return fmt.Errorf("include %v: %v", file, err)
+	}
+
+	return root.storeIncludes(tw, filepath.Dir(file))
+}
@@ -0,0 +1,89 @@
+package tree
+
+import (
+	"sync"
+)
+
+// nodes with fewer childs are scanned, larger ones get a name index
+const indexMin = 8
+
+// indexMu guards the index of every twig, readers under View build it on demand.
+var indexMu sync.RWMutex
+
+func (tw *twig) reindex() {
+	tw.index = make(map[string]int, len(tw.Childs))
+	for i := len(tw.Childs) - 1; i >= 0; i-- {
+		tw.index[tw.Childs[i].Name] = i
+	}
+	tw.indexed = len(tw.Childs)
+}
+
+func (tw *twig) unindex() {
+	indexMu.Lock()
+	defer indexMu.Unlock()
+
+	tw.index = nil
+}
+
+// add appends a child and keeps the index up to date.
+func (tw *twig) add(w twig) {
+	indexMu.Lock()
+	defer indexMu.Unlock()
+
+	valid := (tw.index != nil) && (tw.indexed == len(tw.Childs))
+
+	tw.Childs = append(tw.Childs, w)
+	if valid {
+		if _, ok := tw.index[w.Name]; !ok {
+			tw.index[w.Name] = len(tw.Childs) - 1
+		}
+		tw.indexed = len(tw.Childs)
+	}
+}
+
+// lookup finds name in the index, ok is false when the index must be rebuilt.
+func (tw *twig) lookup(name string) (int, bool) {
+	if (tw.index == nil) || (tw.indexed != len(tw.Childs)) {
+		return -1, false
+	}
+
+	i, ok := tw.index[name]
+	if !ok {
+		return -1, true
+	}
+	if (i < len(tw.Childs)) && (tw.Childs[i].Name == name) {
+		return i, true
+	}
+
+	// stale index, a child was renamed or reordered
+	return -1, false
+}
+
+// child returns the index of the first child named name, or -1.
+func (tw *twig) child(name string) int {
+	if len(tw.Childs) < indexMin {
+		for i := range tw.Childs {
+			if tw.Childs[i].Name == name {
+				return i
+			}
+		}
+		return -1
+	}
+
+	indexMu.RLock()
+	i, ok := tw.lookup(name)
+	indexMu.RUnlock()
+	if ok {
+		return i
+	}
+
+	indexMu.Lock()
+	defer indexMu.Unlock()
+
+	i, ok = tw.lookup(name)
+	if !ok {
+		tw.reindex()
+		i, _ = tw.lookup(name)
+	}
+	return i
+}
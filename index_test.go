@@ -0,0 +1,76 @@
+package tree
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func listTree(n int) *Tree {
+	root := &Tree{Base: &twig{Name: "root"}}
+	root.AddNew("list", nil, nil)
+
+	tw, _ := root.Find([]string{"list"})
+	for i := 0; i < n; i++ {
+		tw.add(twig{Name: "n" + strconv.Itoa(i), Kind: "integer", Value: int64(i)})
+	}
+	return root
+}
+
+func TestFindConcurrent(t *testing.T) {
+	root := listTree(100)
+	tw, _ := root.Find([]string{"list"})
+	tw.unindex()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			root.View(func(t *Tree) error {
+				_, err := t.Find([]string{"list", "n" + strconv.Itoa(i*10)})
+				return err
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	_, err := root.Find([]string{"list", "n99"})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func BenchmarkFind(b *testing.B) {
+	for _, n := range []int{1000, 100000} {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			root := listTree(n)
+			names := []string{"list", "n" + strconv.Itoa(n-1)}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, err := root.Find(names)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkAddNew(b *testing.B) {
+	for _, n := range []int{1000, 100000} {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			root := listTree(n)
+			dst := []string{"list"}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				err := root.AddNew("m"+strconv.Itoa(i), i, dst)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package tree
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+)
+
+type VerifyPolicy int
+
+const (
+	VerifyIgnore VerifyPolicy = iota
+	VerifyWarn
+	VerifyReject
+)
+
+type IntegrityError struct {
+	File   string
+	Reason string
+}
+
+func (e *IntegrityError) Error() string {
+	return fmt.Sprintf("integrity check failed: %v: %v", e.File, e.Reason)
+}
+
+// store writes the file and its "<file>.sha256" and "<file>.sig" sidecars.
+// The signature is made over the SHA-256 digest, so it can be checked while streaming.
+// Until the file is replaced the sidecars hold the lines of the old and the new file,
+// so a crash in between never leaves a file that fails its own check.
+func (root *Tree) store(fileName string, buf []byte) error {
+	err := root.backup(fileName)
+	if err != nil {
+		return err
+	}
+
+	if !root.Checksum && (root.SignKey == nil) {
+		return writeFile(fileName, buf)
+	}
+
+	sum := sha256.Sum256(buf)
+	var sig string
+	if root.SignKey != nil {
+		sig = base64.StdEncoding.EncodeToString(ed25519.Sign(root.SignKey, sum[:]))
+	}
+
+	sidecars := func(keep bool) error {
+		err := writeSidecar(fileName+".sha256", hex.EncodeToString(sum[:]), keep)
+		if (err == nil) && (sig != "") {
+			err = writeSidecar(fileName+".sig", sig, keep)
+		}
+		return err
+	}
+
+	err = sidecars(true)
+	if err == nil {
+		err = writeFile(fileName, buf)
+	}
+	if err == nil {
+		err = sidecars(false)
+	}
+	return err
+}
+
+// writeSidecar writes line to the sidecar, keep keeps the lines it holds.
+func writeSidecar(fileName string, line string, keep bool) error {
+	buf := []byte(line + "\n")
+	if keep {
+		old, err := os.ReadFile(fileName)
+		if err == nil {
+			buf = append(buf, old...)
+		}
+	}
+	return writeFile(fileName, buf)
+}
+
+// sidecarLines returns the decoded lines of a sidecar.
+func sidecarLines(fileName string, decode func(s string) ([]byte, error)) ([][]byte, error) {
+	buf, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	var result [][]byte
+	for _, line := range bytes.Split(buf, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		b, err := decode(string(line))
+		if err == nil {
+			result = append(result, b)
+		}
+	}
+	return result, nil
+}
+
+func (root *Tree) check(fileName string, sum []byte) error {
+	sums, err := sidecarLines(fileName+".sha256", hex.DecodeString)
+	if err != nil {
+		return &IntegrityError{File: fileName, Reason: "checksum is missing"}
+	}
+
+	ok := false
+	for i := range sums {
+		ok = ok || bytes.Equal(sums[i], sum)
+	}
+	if !ok {
+		return &IntegrityError{File: fileName, Reason: "checksum does not match"}
+	}
+
+	if root.VerifyKey == nil {
+		return nil
+	}
+
+	sigs, err := sidecarLines(fileName+".sig", base64.StdEncoding.DecodeString)
+	if err != nil {
+		return &IntegrityError{File: fileName, Reason: "signature is missing"}
+	}
+
+	ok = false
+	for i := range sigs {
+		ok = ok || ed25519.Verify(root.VerifyKey, sum, sigs[i])
+	}
+	if !ok {
+		return &IntegrityError{File: fileName, Reason: "signature is invalid"}
+	}
+
+	return nil
+}
+
+// verify applies the Verify policy to the SHA-256 digest of the file content.
+func (root *Tree) verify(fileName string, sum []byte) error {
+	if root.Verify == VerifyIgnore {
+		return nil
+	}
+
+	err := root.check(fileName, sum)
+	if (err == nil) || (root.Verify == VerifyReject) {
+		return err
+	}
+
+	root.warn(err)
+	return nil
+}
+
+func (root *Tree) warn(err error) {
+	if root.Warn != nil {
+		root.Warn(err)
+	} else {
+		log.Printf("(Warning) %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package tree
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+func (root *Tree) journalName() string {
+	return root.fileName + ".wal"
+}
+
+// journal appends the operations to the write ahead log,
+// a change it can not describe compacts the log into the main file.
+func (root *Tree) journal(patch ...Operation) error {
+	if !root.Journal || root.replaying || (root.fileName == "") {
+		return nil
+	}
+
+	// the journal is plain text without checksum or signature,
+	// an encrypted, checksummed or signed file is always saved whole
+	if (len(patch) == 0) || root.EncryptFile || root.Checksum || (root.SignKey != nil) {
+		return root.compact()
+	}
+
+	var buf []byte
+	for i := range patch {
+		line, err := json.Marshal(patch[i])
+		if err != nil {
+			return err
+		}
+		buf = append(buf, line...)
+		buf = append(buf, '\n')
+	}
+
+	f, err := os.OpenFile(root.journalName(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(buf)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	root.journaled += len(patch)
+	if (root.JournalLimit > 0) && (root.journaled >= root.JournalLimit) {
+		// the change is in the journal, on error it is compacted by a later change
+		root.compact()
+	}
+	return nil
+}
+
+func (root *Tree) replay() error {
+	data, err := os.ReadFile(root.journalName())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+
+	// nothing vouches for the journal, a verified file does not take it unchecked
+	if root.Verify != VerifyIgnore {
+		ierr := &IntegrityError{File: root.journalName(), Reason: "journal is not verified"}
+		if root.Verify == VerifyReject {
+			return ierr
+		}
+		root.warn(ierr)
+	}
+
+	root.replaying = true
+	defer func() { root.replaying = false }()
+
+	n := 0
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(nil, len(data)+1)
+	for sc.Scan() {
+		n++
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var o Operation
+		dec := json.NewDecoder(bytes.NewReader(line))
+		dec.UseNumber()
+		err = dec.Decode(&o)
+		if err != nil {
+			// a torn last line is a write that never finished
+			if !sc.Scan() {
+				break
+			}
+			return fmt.Errorf("journal line %v: %v", n, err)
+		}
+
+		err = root.applyOp(o)
+		if err != nil {
+			return fmt.Errorf("journal line %v: %v", n, err)
+		}
+		root.journaled++
+	}
+
+	return sc.Err()
+}
+
+func (root *Tree) Compact() error {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	return root.compact()
+}
+
+func (root *Tree) compact() error {
+	if root.fileName == "" {
+		return fmt.Errorf("file name is blank")
+	}
+
+	return root.save()
+}
+
+func (root *Tree) truncateJournal() error {
+	root.journaled = 0
+
+	err := os.Remove(root.journalName())
+	if (err != nil) && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
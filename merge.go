@@ -0,0 +1,168 @@
+package tree
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Change struct {
+	Op      string   `json:"op"`
+	Path    []string `json:"path"`
+	From    []string `json:"from,omitempty"`
+	Old     any      `json:"old,omitempty"`
+	New     any      `json:"new,omitempty"`
+	OldKind string   `json:"old_kind,omitempty"`
+	NewKind string   `json:"new_kind,omitempty"`
+}
+
+// MergeStrategy decides the value of a node whose value or kind differs in src and dst.
+// c is the replace Change with Old and New typed by OldKind and NewKind, a kind "" is
+// a null or container node. The returned value is stored with setKind, nil makes it null.
+type MergeStrategy func(c Change) (any, error)
+
+var (
+	MergeOverwrite MergeStrategy = func(c Change) (any, error) {
+		return c.New, nil
+	}
+	MergeKeepExisting MergeStrategy = func(c Change) (any, error) {
+		return c.Old, nil
+	}
+	MergeError MergeStrategy = func(c Change) (any, error) {
+		return nil, fmt.Errorf("merge conflict: %v (%v (%v) != %v (%v))",
+			strings.Join(c.Path, "/"), c.Old, kindName(c.OldKind), c.New, kindName(c.NewKind))
+	}
+)
+
+func kindName(kind string) string {
+	if kind == "" {
+		return "null"
+	}
+	return kind
+}
+
+func (tw *twig) same(o *twig) bool {
+	if tw.Kind != o.Kind {
+		return false
+	}
+	if tw.Kind == "" {
+		return true
+	}
+
+	return tw.get("string") == o.get("string")
+}
+
+// mergeValue is the value of tw as setKind stores it back, secrets, refs and includes keep their kind.
+func mergeValue(tw *twig) any {
+	s, _ := tw.Value.(string)
+	switch tw.Kind {
+	case "secret":
+		return secret(s)
+	case "ref":
+		return Ref(s)
+	case "include":
+		return Include(s)
+	}
+	return tw.get()
+}
+
+func mergeTwig(dst *twig, src *twig, names []string, strategy MergeStrategy, changes *[]Change) error {
+	for i := range src.Childs {
+		stw := &src.Childs[i]
+		path := appendName(names, stw.Name)
+
+		j := dst.child(stw.Name)
+		if j < 0 {
+			w, err := stw.clone()
+			if err != nil {
+				return err
+			}
+			dst.add(w)
+			*changes = append(*changes, Change{Op: "add", Path: path, New: stw.get(), NewKind: stw.Kind})
+			continue
+		}
+		dtw := &dst.Childs[j]
+
+		if !dtw.same(stw) {
+			old := mergeValue(dtw)
+			value, err := strategy(Change{Op: "replace", Path: path, Old: old, New: mergeValue(stw), OldKind: dtw.Kind, NewKind: stw.Kind})
+			if err != nil {
+				return err
+			}
+
+			var w twig
+			err = w.setKind(value)
+			if err != nil {
+				return err
+			}
+			if !dtw.same(&w) {
+				c := Change{Op: "replace", Path: path, Old: old, OldKind: dtw.Kind}
+				dtw.Kind = w.Kind
+				dtw.Value = w.Value
+				c.New = dtw.get()
+				c.NewKind = dtw.Kind
+				*changes = append(*changes, c)
+			}
+		}
+
+		err := mergeTwig(dtw, stw, path, strategy, changes)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (root *Tree) MergeFrom(other *Tree, src []string, dst []string, strategy MergeStrategy) ([]Change, error) {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	return root.mergeFrom(other, src, dst, strategy)
+}
+
+func (root *Tree) mergeFrom(other *Tree, src []string, dst []string, strategy MergeStrategy) ([]Change, error) {
+	var changes []Change
+
+	if strategy == nil {
+		strategy = MergeOverwrite
+	}
+
+	fs, err := other.Find(src)
+	if err != nil {
+		return nil, err
+	}
+
+	fd, err := root.Find(dst)
+	if err != nil {
+		return nil, err
+	}
+
+	// merge into copies, so a conflict leaves the tree untouched
+	sw, err := fs.clone()
+	if err != nil {
+		return nil, err
+	}
+	dw, err := fd.clone()
+	if err != nil {
+		return nil, err
+	}
+	err = mergeTwig(&dw, &sw, dst, strategy, &changes)
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := root.checkpoint()
+	if err != nil {
+		return nil, err
+	}
+	fd.Childs = dw.Childs
+	fd.unindex()
+	return changes, root.recordSince(before)
+}
+
+func (root *Tree) Merge(src []string, dst []string, strategy MergeStrategy) ([]Change, error) {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	return root.mergeFrom(root, src, dst, strategy)
+}
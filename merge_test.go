@@ -0,0 +1,43 @@
+package tree
+
+import (
+	"crypto/rand"
+	"testing"
+)
+
+func TestMergeSecret(t *testing.T) {
+	key := make([]byte, 32)
+	rand.Read(key)
+
+	root := &Tree{Base: &twig{Name: "root"}}
+	root.Keys = KeyFunc(func() ([]byte, error) { return key, nil })
+	root.AddNew("a", nil, nil)
+	root.AddNew("b", nil, nil)
+	root.AddSecret("pw", "new", []string{"a"})
+	root.AddSecret("pw", "old", []string{"b"})
+
+	for _, c := range []struct {
+		strategy MergeStrategy
+		want     string
+	}{
+		{MergeKeepExisting, "old"},
+		{MergeOverwrite, "new"},
+	} {
+		_, err := root.Merge([]string{"a"}, []string{"b"}, c.strategy)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		k, _ := root.GetKind([]string{"b", "pw"})
+		if k != "secret" {
+			t.Fatalf("kind %q", k)
+		}
+		n, err := root.Node([]string{"b", "pw"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := n.Value(); v != c.want {
+			t.Fatalf("%v != %v", v, c.want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package tree
+
+import (
+	"errors"
+)
+
+var ErrStale = errors.New("node is stale")
+
+// Node is a read-only handle to a node in the tree.
+// It is kept by path, so it stays valid across mutations of the tree
+// while the path exists; otherwise Valid is false and Err returns ErrStale.
+type Node struct {
+	root    *Tree
+	tw      *twig
+	path    []string
+	version uint64
+}
+
+func (root *Tree) Node(src []string) (Node, error) {
+	tw, err := root.Find(src)
+	if err != nil {
+		return Node{}, err
+	}
+	return root.node(tw, appendName(src)), nil
+}
+
+func (root *Tree) node(tw *twig, path []string) Node {
+	return Node{root: root, tw: tw, path: path, version: root.version}
+}
+
+// twig returns the cached twig while the tree is unchanged, else looks the path up again.
+func (n Node) twig() (*twig, error) {
+	if n.root == nil {
+		return nil, ErrStale
+	}
+	if (n.tw != nil) && (n.version == n.root.version) {
+		return n.tw, nil
+	}
+
+	tw, err := n.root.Find(n.path)
+	if err != nil {
+		return nil, ErrStale
+	}
+	return tw, nil
+}
+
+func (n Node) Err() error {
+	_, err := n.twig()
+	return err
+}
+
+func (n Node) Valid() bool {
+	return n.Err() == nil
+}
+
+func (n Node) Name() string {
+	if len(n.path) == 0 {
+		if n.root == nil || n.root.Base == nil {
+			return ""
+		}
+		return n.root.Base.Name
+	}
+	return n.path[len(n.path)-1]
+}
+
+func (n Node) Path() []string {
+	return appendName(n.path)
+}
+
+func (n Node) Kind() string {
+	tw, err := n.twig()
+	if err != nil {
+		return ""
+	}
+	return tw.Kind
+}
+
+// Value returns the typed value, secrets are decrypted. It is nil for a null or stale node.
+func (n Node) Value() any {
+	tw, err := n.twig()
+	if err == nil {
+		tw, err = n.root.reveal(tw)
+	}
+	if err != nil {
+		return nil
+	}
+	return tw.get()
+}
+
+func (n Node) Desc() string {
+	tw, err := n.twig()
+	if err != nil {
+		return ""
+	}
+	return tw.Desc
+}
+
+func (n Node) Len() int {
+	tw, err := n.twig()
+	if err != nil {
+		return 0
+	}
+	if tw.load() != nil {
+		return 0
+	}
+	return len(tw.Childs)
+}
+
+func (n Node) Parent() (Node, error) {
+	if len(n.path) == 0 {
+		return Node{}, errors.New("root has no parent")
+	}
+	return n.root.Node(n.path[:len(n.path)-1])
+}
+
+func (n Node) Child(name string) (Node, error) {
+	_, err := n.twig()
+	if err != nil {
+		return Node{}, err
+	}
+	return n.root.Node(appendName(n.path, name))
+}
+
+func (n Node) Children() ([]Node, error) {
+	tw, err := n.twig()
+	if err == nil {
+		err = tw.load()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Node
+	for i := range tw.Childs {
+		ctw := &tw.Childs[i]
+		result = append(result, n.root.node(ctw, appendName(n.path, ctw.Name)))
+	}
+	return result, nil
+}
@@ -0,0 +1,222 @@
+package tree
+
+import (
+	"fmt"
+	"sort"
+)
+
+var (
+	ByName = func(a Node, b Node) bool {
+		return a.Name() < b.Name()
+	}
+
+	// ByValue orders by kind aware value, nodes that do not compare keep their order.
+	ByValue = func(a Node, b Node) bool {
+		x, err := a.twig()
+		if err != nil {
+			return false
+		}
+		y, err := b.twig()
+		if err != nil {
+			return false
+		}
+
+		c, ok := compareTwig(x, y)
+		return ok && (c < 0)
+	}
+)
+
+// reorder puts the childs of src in the order of names, which must hold every child once.
+func (root *Tree) reorder(src []string, names []string) error {
+	tw, err := root.Find(src)
+	if err != nil {
+		return err
+	}
+	if len(names) != len(tw.Childs) {
+		return fmt.Errorf("do not match childs: %v", names)
+	}
+
+	var old []string
+	seen := make(map[string]bool, len(names))
+	childs := make([]twig, 0, len(tw.Childs))
+	for i := range names {
+		j := tw.child(names[i])
+		if j < 0 {
+			return fmt.Errorf("not found: %v", names[i])
+		}
+		if seen[names[i]] {
+			return fmt.Errorf("duplicate name. %v", names[i])
+		}
+		seen[names[i]] = true
+
+		childs = append(childs, tw.Childs[j])
+		old = append(old, tw.Childs[i].Name)
+	}
+
+	tw.Childs = childs
+	tw.unindex()
+
+	src = appendName(src)
+	names = appendName(names)
+	return root.record(func(root *Tree) error {
+		return root.reorder(src, old)
+	}, func(root *Tree) error {
+		return root.reorder(src, names)
+	}, Operation{Op: "order", Path: src, Value: names})
+}
+
+// MoveTo moves src to index among its siblings, a negative index counts from the end.
+func (root *Tree) MoveTo(src []string, index int) error {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	return root.moveTo(src, index)
+}
+
+func (root *Tree) moveTo(src []string, index int) error {
+	if len(src) == 0 {
+		return fmt.Errorf("path is blank")
+	}
+
+	names, err := root.List(src[:len(src)-1])
+	if err != nil {
+		return err
+	}
+
+	name := src[len(src)-1]
+	var list []string
+	for i := range names {
+		if names[i] != name {
+			list = append(list, names[i])
+		}
+	}
+	if len(list) == len(names) {
+		return fmt.Errorf("not found: %v", name)
+	}
+
+	if index < 0 {
+		index += len(names)
+	}
+	if (index < 0) || (index > len(list)) {
+		return fmt.Errorf("index out of range: %v", index)
+	}
+
+	list = append(list[:index], append([]string{name}, list[index:]...)...)
+	return root.reorder(src[:len(src)-1], list)
+}
+
+// InsertBefore adds a new node in front of the sibling at.
+func (root *Tree) InsertBefore(name string, value any, at []string) error {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	return root.insertAt(name, value, at, 0)
+}
+
+// InsertAfter adds a new node behind the sibling at.
+func (root *Tree) InsertAfter(name string, value any, at []string) error {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	return root.insertAt(name, value, at, 1)
+}
+
+func (root *Tree) insertAt(name string, value any, at []string, offset int) error {
+	if len(at) == 0 {
+		return fmt.Errorf("path is blank")
+	}
+
+	dst := at[:len(at)-1]
+	tw, err := root.Find(dst)
+	if err != nil {
+		return err
+	}
+
+	index := tw.child(at[len(at)-1])
+	if index < 0 {
+		return fmt.Errorf("not found: %v", at[len(at)-1])
+	}
+
+	root.beginGroup()
+	defer root.endGroup()
+
+	err = root.addNew(name, value, dst)
+	if err != nil {
+		return err
+	}
+	return root.moveTo(appendName(dst, name), index+offset)
+}
+
+// SortChildren sorts the childs of src stable by less, e.g. ByName or ByValue.
+func (root *Tree) SortChildren(src []string, less func(a Node, b Node) bool) error {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	return root.sortChildren(src, less)
+}
+
+func (root *Tree) sortChildren(src []string, less func(a Node, b Node) bool) error {
+	tw, err := root.Find(src)
+	if err != nil {
+		return err
+	}
+
+	nodes := make([]Node, 0, len(tw.Childs))
+	for i := range tw.Childs {
+		ctw, err := root.reveal(&tw.Childs[i])
+		if err != nil {
+			return err
+		}
+		nodes = append(nodes, root.node(ctw, appendName(src, tw.Childs[i].Name)))
+	}
+
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return less(nodes[i], nodes[j])
+	})
+
+	var names []string
+	for i := range nodes {
+		names = append(names, nodes[i].Name())
+	}
+	return root.reorder(src, names)
+}
+
+func (root *Tree) Rename(src []string, name string) error {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	return root.rename(src, name)
+}
+
+func (root *Tree) rename(src []string, name string) error {
+	if name == "" {
+		return fmt.Errorf(".Name blank.%v", name)
+	}
+	if len(src) == 0 {
+		return fmt.Errorf("path is blank")
+	}
+
+	tw, tr, err := root.findPlus(src)
+	if err != nil {
+		return err
+	}
+
+	old := tw.Name
+	if old == name {
+		return nil
+	}
+	if tr.child(name) >= 0 {
+		return fmt.Errorf("duplicate name. %v", name)
+	}
+
+	tw.Name = name
+	tr.unindex()
+
+	src = appendName(src)
+	dst := appendName(src[:len(src)-1], name)
+	return root.record(func(root *Tree) error {
+		return root.rename(dst, old)
+	}, func(root *Tree) error {
+		return root.rename(src, name)
+	}, Operation{Op: "rename", Path: src, Value: name})
+}
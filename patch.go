@@ -0,0 +1,311 @@
+package tree
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Operation is one step of a patch. Path and From are tree paths,
+// Kind converts Value (e.g. "2025-06-10T..." to datetime) before it is stored.
+type Operation struct {
+	Op    string   `json:"op"`
+	Path  []string `json:"path"`
+	From  []string `json:"from,omitempty"`
+	Value any      `json:"value,omitempty"`
+	Kind  string   `json:"kind,omitempty"`
+}
+
+func (o *Operation) value() (any, error) {
+	// the value of a secret is stored sealed, as written by the journal
+	if o.Kind == "secret" {
+		s, ok := o.Value.(string)
+		if !ok {
+			return nil, fmt.Errorf("secret is not string")
+		}
+		return secret(s), nil
+	}
+
+	if n, ok := o.Value.(json.Number); ok {
+		if o.Kind != "" {
+			return parseKind(o.Kind, n.String())
+		}
+		if v, err := n.Int64(); err == nil {
+			return v, nil
+		}
+		return n.Float64()
+	}
+
+	if (o.Kind == "") || (o.Value == nil) {
+		return o.Value, nil
+	}
+
+	if s, ok := o.Value.(string); ok {
+		return parseKind(o.Kind, s)
+	}
+
+	var tw twig
+	err := tw.setKind(o.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	v := tw.get(o.Kind)
+	if v == nil {
+		return nil, fmt.Errorf("not found kind: %v", o.Kind)
+	}
+	return v, nil
+}
+
+func (root *Tree) applyOp(o Operation) error {
+	switch o.Op {
+	case "add":
+		if len(o.Path) == 0 {
+			return fmt.Errorf("path is blank")
+		}
+
+		value, err := o.value()
+		if err != nil {
+			return err
+		}
+
+		_, err = root.Find(o.Path)
+		if err == nil {
+			return root.setValue(value, o.Path)
+		}
+
+		n := len(o.Path) - 1
+		return root.addNew(o.Path[n], value, o.Path[:n])
+	case "remove":
+		return root.deleteNode(o.Path)
+	case "replace":
+		value, err := o.value()
+		if err != nil {
+			return err
+		}
+
+		return root.setValue(value, o.Path)
+	case "desc":
+		desc, _ := o.Value.(string)
+		return root.setDesc(desc, o.Path)
+	case "move":
+		// Move keeps the childs of dst on a conflict, a patch fails instead;
+		// the journal holds what Move did and is replayed as such
+		if !root.replaying {
+			err := root.moveConflict(o.From, o.Path)
+			if err != nil {
+				return err
+			}
+		}
+		return root.moveNode(o.From, o.Path)
+	case "copy":
+		m, _ := o.Value.(bool)
+		return root.copyNode(o.From, o.Path, m)
+	case "rename":
+		name, _ := o.Value.(string)
+		return root.rename(o.Path, name)
+	case "order":
+		var names []string
+		switch x := o.Value.(type) {
+		case []string:
+			names = x
+		case []any:
+			for i := range x {
+				name, _ := x[i].(string)
+				names = append(names, name)
+			}
+		}
+		return root.reorder(o.Path, names)
+	case "test":
+		tw, err := root.Find(o.Path)
+		if err != nil {
+			return err
+		}
+
+		if o.Kind == "" {
+			o.Kind = tw.Kind
+		}
+		value, err := o.value()
+		if err != nil {
+			return err
+		}
+
+		var w twig
+		err = w.setKind(value)
+		if err != nil {
+			return err
+		}
+
+		if !tw.same(&w) {
+			return fmt.Errorf("test failed: %v", strings.Join(o.Path, "/"))
+		}
+		return nil
+	}
+
+	return fmt.Errorf("not found op: %v", o.Op)
+}
+
+// moveConflict reports a child of src whose name is taken in dst.
+func (root *Tree) moveConflict(src []string, dst []string) error {
+	ts, err := root.Find(src)
+	if err != nil {
+		return err
+	}
+	td, err := root.Find(dst)
+	if err != nil {
+		return err
+	}
+
+	for i := range ts.Childs {
+		if td.child(ts.Childs[i].Name) >= 0 {
+			return fmt.Errorf("duplicate name. %v", ts.Childs[i].Name)
+		}
+	}
+	return nil
+}
+
+func (root *Tree) Apply(patch []Operation) error {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	return root.apply(patch)
+}
+
+func (root *Tree) apply(patch []Operation) error {
+	if root.Base == nil {
+		return fmt.Errorf("root is NULL")
+	}
+
+	// apply to a copy, so the tree is changed all or nothing
+	work, err := root.clone()
+	if err != nil {
+		return err
+	}
+	for i := range patch {
+		err := work.applyOp(patch[i])
+		if err != nil {
+			return fmt.Errorf("patch[%v] %v %v: %v", i, patch[i].Op, strings.Join(patch[i].Path, "/"), err)
+		}
+	}
+
+	before, err := root.checkpoint()
+	if err != nil {
+		return err
+	}
+	root.Base = work.Base
+	return root.recordSince(before)
+}
+
+func (root *Tree) ApplyJSON(data []byte) error {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	var patch []Operation
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	err := dec.Decode(&patch)
+	if err != nil {
+		return err
+	}
+
+	return root.apply(patch)
+}
+
+func mergePatch(root *Tree, data []byte, names []string) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if t != json.Delim('{') {
+		return fmt.Errorf("merge patch is not object: %v", strings.Join(names, "/"))
+	}
+
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		name := t.(string)
+		path := appendName(names, name)
+
+		var raw json.RawMessage
+		err = dec.Decode(&raw)
+		if err != nil {
+			return err
+		}
+
+		_, ferr := root.Find(path)
+		switch {
+		case bytes.Equal(raw, []byte("null")):
+			if ferr == nil {
+				err = root.deleteNode(path)
+			}
+		case raw[0] == '{':
+			if ferr != nil {
+				err = root.addNew(name, nil, names)
+			}
+			if err == nil {
+				err = mergePatch(root, raw, path)
+			}
+		default:
+			var value any
+			err = json.Unmarshal(raw, &value)
+			if err != nil {
+				return err
+			}
+			if (raw[0] != '"') && !bytes.ContainsAny(raw, ".eE") {
+				value, err = json.Number(raw).Int64()
+				if err != nil {
+					return err
+				}
+			}
+
+			if ferr == nil {
+				err = root.setValue(value, path)
+			} else {
+				err = root.addNew(name, value, names)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ApplyMergePatch applies a JSON merge patch (RFC 7396 style) below dst:
+// objects are merged into childs, null removes and other values are set.
+func (root *Tree) ApplyMergePatch(data []byte, dst []string) error {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	return root.applyMergePatch(data, dst)
+}
+
+func (root *Tree) applyMergePatch(data []byte, dst []string) error {
+	_, err := root.Find(dst)
+	if err != nil {
+		return err
+	}
+
+	work, err := root.clone()
+	if err != nil {
+		return err
+	}
+	err = mergePatch(work, bytes.TrimSpace(data), dst)
+	if err != nil {
+		return err
+	}
+
+	before, err := root.checkpoint()
+	if err != nil {
+		return err
+	}
+	root.Base = work.Base
+	return root.recordSince(before)
+}
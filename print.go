@@ -0,0 +1,160 @@
+package tree
+
+import (
+	"fmt"
+	"log/slog"
+	"path"
+	"strings"
+)
+
+// redacted reports whether the value of the node is hidden when printed.
+// A Redact pattern with "/" is matched with the whole path, otherwise with the name.
+func (root *Tree) redacted(names []string, tw *twig) bool {
+	if tw.Kind == "secret" {
+		return true
+	}
+
+	full := strings.Join(names, "/")
+	for i := range root.Redact {
+		p := root.Redact[i]
+
+		s := tw.Name
+		if strings.Contains(p, "/") {
+			s = full
+		}
+		if ok, _ := path.Match(p, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// hidden reports whether a node above names is redacted, which hides its whole subtree.
+func (root *Tree) hidden(names []string) bool {
+	for i := 1; i < len(names); i++ {
+		if root.redacted(names[:i], &twig{Name: names[i-1]}) {
+			return true
+		}
+	}
+	return false
+}
+
+func (root *Tree) printValue(names []string, tw *twig) string {
+	if root.redacted(names, tw) || root.hidden(names) {
+		return secretMask
+	}
+	return tw.get("string").(string)
+}
+
+// print writes the subtree, hidden is set below a redacted node.
+func (root *Tree) print(sb *strings.Builder, tw *twig, names []string, depth int, kinds bool, hidden bool) {
+	sb.WriteString(strings.Repeat("  ", depth))
+	sb.WriteString(tw.Name)
+
+	hidden = hidden || root.redacted(names, tw)
+	if tw.Kind != "" {
+		v := secretMask
+		if !hidden {
+			v = tw.get("string").(string)
+		}
+		if (tw.Kind == "string") && (v != secretMask) {
+			v = fmt.Sprintf("%q", v)
+		}
+		sb.WriteString(": " + v)
+		if kinds {
+			sb.WriteString(" (" + tw.Kind + ")")
+		}
+	}
+	if kinds && (tw.Desc != "") {
+		sb.WriteString(" # " + tw.Desc)
+	}
+	sb.WriteString("\n")
+
+	for i := range tw.Childs {
+		ctw := &tw.Childs[i]
+		root.print(sb, ctw, appendName(names, ctw.Name), depth+1, kinds, hidden)
+	}
+}
+
+func (root *Tree) sprint(src []string, kinds bool) (string, error) {
+	var sb strings.Builder
+
+	tw, err := root.Find(src)
+	if err == nil {
+		err = tw.loadAll()
+	}
+	if err != nil {
+		return "", err
+	}
+
+	root.print(&sb, tw, src, 0, kinds, root.hidden(src))
+	return sb.String(), nil
+}
+
+// Sprint renders the subtree at src as an indented view with redacted values,
+// kinds prints kinds and descriptions as well.
+func (root *Tree) Sprint(src []string, kinds ...bool) (string, error) {
+	return root.sprint(src, (len(kinds) != 0) && kinds[0])
+}
+
+func (root *Tree) String() string {
+	s, err := root.sprint(nil, false)
+	if err != nil {
+		return "<" + err.Error() + ">"
+	}
+	return s
+}
+
+// Format prints kinds and descriptions as well with %+v.
+func (root *Tree) Format(f fmt.State, verb rune) {
+	switch verb {
+	case 'v', 's':
+		s, err := root.sprint(nil, f.Flag('+'))
+		if err != nil {
+			s = "<" + err.Error() + ">"
+		}
+		fmt.Fprint(f, s)
+	default:
+		fmt.Fprintf(f, "%%!%c(tree.Tree)", verb)
+	}
+}
+
+// logValue is the subtree as a slog group, hidden is set below a redacted node.
+func (root *Tree) logValue(tw *twig, names []string, hidden bool) slog.Value {
+	hidden = hidden || root.redacted(names, tw)
+	if len(tw.Childs) == 0 {
+		if tw.Kind == "" {
+			return slog.AnyValue(nil)
+		}
+		if hidden {
+			return slog.StringValue(secretMask)
+		}
+		return slog.AnyValue(tw.get())
+	}
+
+	var attrs []slog.Attr
+	if tw.Kind != "" {
+		v := secretMask
+		if !hidden {
+			v = tw.get("string").(string)
+		}
+		attrs = append(attrs, slog.String("value", v))
+	}
+	for i := range tw.Childs {
+		ctw := &tw.Childs[i]
+		attrs = append(attrs, slog.Attr{Key: ctw.Name, Value: root.logValue(ctw, appendName(names, ctw.Name), hidden)})
+	}
+	return slog.GroupValue(attrs...)
+}
+
+func (root *Tree) LogValue() slog.Value {
+	if root.Base == nil {
+		return slog.AnyValue(nil)
+	}
+
+	err := root.Base.loadAll()
+	if err != nil {
+		return slog.StringValue("<" + err.Error() + ">")
+	}
+	return root.logValue(root.Base, nil, false)
+}
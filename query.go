@@ -0,0 +1,669 @@
+package tree
+
+import (
+	"cmp"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// A query selects nodes by path and filters them, e.g.
+//
+//	work/*/table/*[int > 2 && bool == true] | sort -int,name | select name,int | limit 10
+//
+// Path segments are glob patterns (path.Match), "**" matches any number of levels.
+// A segment may be followed by a [predicate] on the childs of the matched node,
+// "." is the value of the node itself and "name" falls back to the node name.
+// Literals are converted to the kind of the other side, so datetime and float
+// fields compare as such.
+type query struct {
+	segs   []querySeg
+	sorts  []querySort
+	fields []string
+	limit  int
+}
+
+type querySeg struct {
+	pattern string
+	pred    *expr
+}
+
+type querySort struct {
+	field string
+	desc  bool
+}
+
+type queryItem struct {
+	tw   *twig
+	path []string
+}
+
+// Query returns the nodes matching q in the order of the tree or of its sort stage.
+func (root *Tree) Query(q string) ([]Node, error) {
+	qq, err := parseQuery(q)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := root.query(qq)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Node
+	for i := range items {
+		result = append(result, root.node(items[i].tw, items[i].path))
+	}
+	return result, nil
+}
+
+// QueryMaps returns the matching nodes in the layout of GetMaping,
+// limited to the fields of the select stage.
+func (root *Tree) QueryMaps(q string) ([]map[string]any, error) {
+	qq, err := parseQuery(q)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := root.query(qq)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []map[string]any
+	for i := range items {
+		m, err := root.maping(items[i].tw, "name")
+		if err != nil {
+			return result, err
+		}
+
+		if len(qq.fields) != 0 {
+			p := make(map[string]any)
+			for _, f := range qq.fields {
+				if v, ok := m[f]; ok {
+					p[f] = v
+				}
+			}
+			m = p
+		}
+		result = append(result, m)
+	}
+	return result, nil
+}
+
+func (root *Tree) query(qq *query) ([]queryItem, error) {
+	if root.Base == nil {
+		return nil, fmt.Errorf("root is NULL")
+	}
+	return root.queryFrom([]queryItem{{tw: root.Base}}, qq)
+}
+
+func (root *Tree) queryFrom(items []queryItem, qq *query) ([]queryItem, error) {
+	for _, seg := range qq.segs {
+		var next []queryItem
+		seen := make(map[*twig]bool)
+
+		add := func(it queryItem) error {
+			if seen[it.tw] {
+				return nil
+			}
+			seen[it.tw] = true
+
+			if seg.pred != nil {
+				ok, err := seg.pred.match(root, it.tw)
+				if (err != nil) || !ok {
+					return err
+				}
+			}
+			next = append(next, it)
+			return nil
+		}
+
+		for _, it := range items {
+			if seg.pattern == "**" {
+				err := root.Walk(it.path, func(path []string, node Node) error {
+					return add(queryItem{tw: node.tw, path: path})
+				})
+				if err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			err := it.tw.load()
+			if err != nil {
+				return nil, err
+			}
+			for i := range it.tw.Childs {
+				ctw := &it.tw.Childs[i]
+				if ok, _ := path.Match(seg.pattern, ctw.Name); !ok {
+					continue
+				}
+				err = add(queryItem{tw: ctw, path: appendName(it.path, ctw.Name)})
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		items = next
+	}
+
+	if len(qq.sorts) != 0 {
+		var err error
+		sort.SliceStable(items, func(i, j int) bool {
+			for _, s := range qq.sorts {
+				a, e1 := fieldTwig(root, items[i].tw, s.field)
+				b, e2 := fieldTwig(root, items[j].tw, s.field)
+				if e1 != nil {
+					err = e1
+				}
+				if e2 != nil {
+					err = e2
+				}
+
+				// missing fields are sorted last
+				if (a == nil) || (b == nil) {
+					if (a == nil) != (b == nil) {
+						return b == nil
+					}
+					continue
+				}
+
+				c, ok := compareTwig(a, b)
+				if !ok || (c == 0) {
+					continue
+				}
+				if s.desc {
+					return c > 0
+				}
+				return c < 0
+			}
+			return false
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if (qq.limit > 0) && (len(items) > qq.limit) {
+		items = items[:qq.limit]
+	}
+	return items, nil
+}
+
+func parseQuery(q string) (*query, error) {
+	stages := splitTop(q, '|')
+
+	var qq query
+	for _, seg := range splitTop(strings.Trim(strings.TrimSpace(stages[0]), "/"), '/') {
+		seg = strings.TrimSpace(seg)
+		if seg == "" {
+			continue
+		}
+
+		var s querySeg
+		if i := strings.IndexByte(seg, '['); i >= 0 {
+			if !strings.HasSuffix(seg, "]") {
+				return nil, fmt.Errorf("query is incorrect: %v", seg)
+			}
+			pred, err := parseExpr(seg[i+1 : len(seg)-1])
+			if err != nil {
+				return nil, err
+			}
+			s.pred = pred
+			seg = strings.TrimSpace(seg[:i])
+		}
+		if _, err := path.Match(seg, ""); err != nil {
+			return nil, fmt.Errorf("query is incorrect: %v: %v", seg, err)
+		}
+		s.pattern = seg
+		qq.segs = append(qq.segs, s)
+	}
+
+	for _, stage := range stages[1:] {
+		words := strings.Fields(stage)
+		if len(words) == 0 {
+			return nil, fmt.Errorf("query is incorrect: empty stage")
+		}
+		args := strings.Split(strings.Join(words[1:], ""), ",")
+
+		switch words[0] {
+		case "sort":
+			for _, a := range args {
+				if a == "" {
+					continue
+				}
+				s := querySort{field: a}
+				if strings.HasPrefix(a, "-") {
+					s = querySort{field: a[1:], desc: true}
+				}
+				qq.sorts = append(qq.sorts, s)
+			}
+		case "select":
+			for _, a := range args {
+				if a != "" {
+					qq.fields = append(qq.fields, a)
+				}
+			}
+		case "limit":
+			n, err := strconv.Atoi(strings.Join(args, ""))
+			if err != nil {
+				return nil, fmt.Errorf("query is incorrect: %v", stage)
+			}
+			qq.limit = n
+		default:
+			return nil, fmt.Errorf("not found stage: %v", words[0])
+		}
+	}
+
+	return &qq, nil
+}
+
+// splitTop splits s at sep outside of quotes and brackets.
+func splitTop(s string, sep byte) []string {
+	var result []string
+	var quote byte
+	depth := 0
+	start := 0
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case (c == '"') || (c == '\''):
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case (c == sep) && (depth == 0):
+			result = append(result, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(result, s[start:])
+}
+
+// expr is a predicate, see query.
+type expr struct {
+	op    string
+	left  *expr
+	right *expr
+	a     *operand
+	b     *operand
+}
+
+type operand struct {
+	field string
+	kind  string
+	text  string
+	lit   bool
+}
+
+type token struct {
+	kind string
+	text string
+}
+
+func lexExpr(s string) ([]token, error) {
+	var result []token
+
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case strings.HasPrefix(s[i:], "&&"), strings.HasPrefix(s[i:], "||"),
+			strings.HasPrefix(s[i:], "=="), strings.HasPrefix(s[i:], "!="),
+			strings.HasPrefix(s[i:], "<="), strings.HasPrefix(s[i:], ">="):
+			result = append(result, token{kind: "op", text: s[i : i+2]})
+			i += 2
+		case c == '<' || c == '>' || c == '!' || c == '(' || c == ')':
+			result = append(result, token{kind: "op", text: s[i : i+1]})
+			i++
+		case c == '"' || c == '\'':
+			j := i + 1
+			var sb strings.Builder
+			for ; (j < len(s)) && (s[j] != c); j++ {
+				if (s[j] == '\\') && (j+1 < len(s)) {
+					j++
+				}
+				sb.WriteByte(s[j])
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("string is not closed: %v", s[i:])
+			}
+			result = append(result, token{kind: "string", text: sb.String()})
+			i = j + 1
+		case (c >= '0' && c <= '9') || (c == '-' && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '9'):
+			j := i + 1
+			for (j < len(s)) && strings.IndexByte("0123456789.eE+-", s[j]) >= 0 {
+				j++
+			}
+			result = append(result, token{kind: "number", text: s[i:j]})
+			i = j
+		default:
+			j := i
+			for j < len(s) {
+				r := rune(s[j])
+				if !(unicode.IsLetter(r) || unicode.IsDigit(r) || (r >= 0x80) || strings.ContainsRune("_-./", r)) {
+					break
+				}
+				j++
+			}
+			if j == i {
+				return nil, fmt.Errorf("expression is incorrect: %v", s[i:])
+			}
+			result = append(result, token{kind: "field", text: s[i:j]})
+			i = j
+		}
+	}
+
+	return result, nil
+}
+
+type exprParser struct {
+	toks []token
+	pos  int
+}
+
+func parseExpr(s string) (*expr, error) {
+	toks, err := lexExpr(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(toks) == 0 {
+		return nil, fmt.Errorf("expression is empty")
+	}
+
+	p := &exprParser{toks: toks}
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, fmt.Errorf("expression is incorrect: %v", p.toks[p.pos].text)
+	}
+	return e, nil
+}
+
+func (p *exprParser) peek() token {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos]
+	}
+	return token{}
+}
+
+func (p *exprParser) or() (*expr, error) {
+	e, err := p.and()
+	for (err == nil) && (p.peek().text == "||") {
+		p.pos++
+		var r *expr
+		r, err = p.and()
+		e = &expr{op: "||", left: e, right: r}
+	}
+	return e, err
+}
+
+func (p *exprParser) and() (*expr, error) {
+	e, err := p.unary()
+	for (err == nil) && (p.peek().text == "&&") {
+		p.pos++
+		var r *expr
+		r, err = p.unary()
+		e = &expr{op: "&&", left: e, right: r}
+	}
+	return e, err
+}
+
+func (p *exprParser) unary() (*expr, error) {
+	t := p.peek()
+	if (t.kind == "op") && (t.text == "!") {
+		p.pos++
+		e, err := p.unary()
+		return &expr{op: "!", left: e}, err
+	}
+
+	if (t.kind == "op") && (t.text == "(") {
+		p.pos++
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().text != ")" {
+			return nil, fmt.Errorf("expression is incorrect: missing )")
+		}
+		p.pos++
+		return e, nil
+	}
+
+	a, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+
+	t = p.peek()
+	switch t.text {
+	case "==", "!=", "<", "<=", ">", ">=":
+		p.pos++
+		b, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		return &expr{op: t.text, a: a, b: b}, nil
+	}
+	return &expr{a: a}, nil
+}
+
+func (p *exprParser) operand() (*operand, error) {
+	t := p.peek()
+	p.pos++
+
+	switch t.kind {
+	case "string":
+		return &operand{lit: true, kind: "string", text: t.text}, nil
+	case "number":
+		if strings.ContainsAny(t.text, ".eE") {
+			return &operand{lit: true, kind: "float", text: t.text}, nil
+		}
+		return &operand{lit: true, kind: "integer", text: t.text}, nil
+	case "field":
+		switch t.text {
+		case "true", "false":
+			return &operand{lit: true, kind: "bool", text: t.text}, nil
+		case "null":
+			return &operand{lit: true, kind: "", text: t.text}, nil
+		}
+		return &operand{field: t.text}, nil
+	}
+
+	if t.text == "" {
+		return nil, fmt.Errorf("expression is incorrect: missing operand")
+	}
+	return nil, fmt.Errorf("expression is incorrect: %v", t.text)
+}
+
+// fieldTwig finds the field of tw, nil if it does not exist.
+func fieldTwig(root *Tree, tw *twig, field string) (*twig, error) {
+	if field == "." {
+		return root.reveal(tw)
+	}
+
+	r := tw
+	for _, name := range strings.Split(field, "/") {
+		err := r.load()
+		if err != nil {
+			return nil, err
+		}
+
+		j := r.child(name)
+		if j < 0 {
+			if (r == tw) && (field == "name") {
+				return &twig{Name: "name", Kind: "string", Value: tw.Name}, nil
+			}
+			return nil, nil
+		}
+		r = &r.Childs[j]
+	}
+	return root.reveal(r)
+}
+
+// literal converts the text of a literal to the kind of the other side.
+func (o *operand) literal(kind string) *twig {
+	if o.kind == "" {
+		return &twig{}
+	}
+
+	if (kind != "") && (kind != o.kind) {
+		if v, err := parseKind(kind, o.text); err == nil {
+			var w twig
+			if w.setKind(v) == nil {
+				return &w
+			}
+		}
+	}
+
+	v, _ := parseKind(o.kind, o.text)
+	var w twig
+	w.setKind(v)
+	return &w
+}
+
+func (e *expr) match(root *Tree, tw *twig) (bool, error) {
+	switch e.op {
+	case "&&", "||":
+		l, err := e.left.match(root, tw)
+		if (err != nil) || (l == (e.op == "||")) {
+			return l, err
+		}
+		return e.right.match(root, tw)
+	case "!":
+		l, err := e.left.match(root, tw)
+		return !l, err
+	}
+
+	var x, y *twig
+	var err error
+	if !e.a.lit {
+		x, err = fieldTwig(root, tw, e.a.field)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if e.op == "" {
+		if e.a.lit {
+			x = e.a.literal("")
+		}
+		if (x == nil) || (x.Kind == "") {
+			return false, nil
+		}
+		if x.Kind == "bool" {
+			return x.get().(bool), nil
+		}
+		return true, nil
+	}
+
+	if !e.b.lit {
+		y, err = fieldTwig(root, tw, e.b.field)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if e.a.lit {
+		if y != nil {
+			x = e.a.literal(y.Kind)
+		} else {
+			x = e.a.literal("")
+		}
+	}
+	if e.b.lit {
+		if x != nil {
+			y = e.b.literal(x.Kind)
+		} else {
+			y = e.b.literal("")
+		}
+	}
+
+	// a missing field is equal to null only
+	if x == nil {
+		x = &twig{}
+	}
+	if y == nil {
+		y = &twig{}
+	}
+
+	c, ok := compareTwig(x, y)
+	switch e.op {
+	case "==":
+		return ok && (c == 0), nil
+	case "!=":
+		return !ok || (c != 0), nil
+	case "<":
+		return ok && (c < 0), nil
+	case "<=":
+		return ok && (c <= 0), nil
+	case ">":
+		return ok && (c > 0), nil
+	case ">=":
+		return ok && (c >= 0), nil
+	}
+	return false, fmt.Errorf("not found operator: %v", e.op)
+}
+
+// compareTwig compares the values of a and b, ok is false if the kinds do not compare.
+func compareTwig(a *twig, b *twig) (int, bool) {
+	if (a.Kind == "") || (b.Kind == "") {
+		if a.Kind == b.Kind {
+			return 0, true
+		}
+		return 0, false
+	}
+
+	numeric := func(kind string) bool {
+		return (kind == "integer") || (kind == "float")
+	}
+
+	switch {
+	case (a.Kind == "integer") && (b.Kind == "integer"):
+		x, y := a.get().(int64), b.get().(int64)
+		return cmp.Compare(x, y), true
+	case numeric(a.Kind) && numeric(b.Kind):
+		x, y := a.get("float").(float64), b.get("float").(float64)
+		return cmp.Compare(x, y), true
+	case a.Kind != b.Kind:
+		return 0, false
+	}
+
+	switch a.Kind {
+	case "string":
+		return strings.Compare(a.get().(string), b.get().(string)), true
+	case "bool":
+		x, y := a.get().(bool), b.get().(bool)
+		if x == y {
+			return 0, true
+		}
+		if y {
+			return -1, true
+		}
+		return 1, true
+	case "datetime":
+		x, ok1 := a.get().(time.Time)
+		y, ok2 := b.get().(time.Time)
+		if !ok1 || !ok2 {
+			return 0, false
+		}
+		return x.Compare(y), true
+	}
+	return 0, false
+}
@@ -0,0 +1,140 @@
+package tree
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Ref is the value of a ref node, the slash separated path of the node it points to.
+// Find and the getters follow refs, Delete and Rename work on the ref itself.
+type Ref string
+
+func refPath(tw *twig) []string {
+	s, _ := tw.Value.(string)
+	s = strings.Trim(s, "/")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "/")
+}
+
+// resolve finds names following refs on the way, final also follows a ref at the end.
+// It returns the node and its parent.
+func (root *Tree) resolve(names []string, final bool, seen map[*twig]bool) (*twig, *twig, error) {
+	if root.Base == nil {
+		return nil, nil, fmt.Errorf("root is NULL: %v", names)
+	}
+
+	r := root.Base
+	v := root.Base
+	for i := range names {
+		err := r.load()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		j := r.child(names[i])
+		if j < 0 {
+			return nil, nil, fmt.Errorf("not found: %v", names[i])
+		}
+		v = r
+		r = &r.Childs[j]
+
+		if (r.Kind == "ref") && (final || (i < len(names)-1)) {
+			if seen == nil {
+				seen = make(map[*twig]bool)
+			}
+			r, err = root.follow(r, seen)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	return r, v, nil
+}
+
+func (root *Tree) follow(tw *twig, seen map[*twig]bool) (*twig, error) {
+	if seen[tw] {
+		return nil, fmt.Errorf("ref cycle: %v", tw.Value)
+	}
+	seen[tw] = true
+	defer delete(seen, tw)
+
+	r, _, err := root.resolve(refPath(tw), true, seen)
+	if err != nil {
+		return nil, fmt.Errorf("ref %v: %v", tw.Value, err)
+	}
+	return r, nil
+}
+
+// GetRef returns the path a ref node points to.
+func (root *Tree) GetRef(src []string) (string, error) {
+	tw, _, err := root.resolve(src, false, nil)
+	if err != nil {
+		return "", err
+	}
+
+	if tw.Kind != "ref" {
+		return "", fmt.Errorf("not a ref: %v", src)
+	}
+	return tw.Value.(string), nil
+}
+
+// export returns the tree to write, with ResolveRefs the refs are replaced by their targets.
+// The childs of include nodes are left out, they are written to their own files.
+func (root *Tree) export() (*twig, error) {
+	base := root.Base
+	if root.ResolveRefs && (base != nil) {
+		w, err := root.materialize(base, nil)
+		if err != nil {
+			return nil, err
+		}
+		base = &w
+	}
+
+	if (base != nil) && hasIncludes(base) {
+		w := stripIncludes(base)
+		base = &w
+	}
+	return base, nil
+}
+
+// materialize copies tw with every ref replaced by a copy of its target.
+func (root *Tree) materialize(tw *twig, seen map[*twig]bool) (twig, error) {
+	if seen == nil {
+		seen = make(map[*twig]bool)
+	}
+
+	w := twig{Name: tw.Name, Kind: tw.Kind, Value: tw.Value, Desc: tw.Desc, inc: tw.inc}
+	src := tw
+	if tw.Kind == "ref" {
+		if seen[tw] {
+			return w, fmt.Errorf("ref cycle: %v", tw.Value)
+		}
+		seen[tw] = true
+		defer delete(seen, tw)
+
+		// seen holds the refs being expanded, follow only checks the chain of one ref
+		t, err := root.follow(tw, make(map[*twig]bool))
+		if err != nil {
+			return w, err
+		}
+		w.Kind = t.Kind
+		w.Value = t.Value
+		src = t
+	}
+
+	err := src.load()
+	if err != nil {
+		return w, err
+	}
+	for i := range src.Childs {
+		c, err := root.materialize(&src.Childs[i], seen)
+		if err != nil {
+			return w, err
+		}
+		w.Childs = append(w.Childs, c)
+	}
+	return w, nil
+}
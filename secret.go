@@ -0,0 +1,196 @@
+package tree
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// KeyProvider returns the AES key (16, 24 or 32 bytes) for secret values and encrypted files.
+type KeyProvider interface {
+	Key() ([]byte, error)
+}
+
+type KeyFunc func() ([]byte, error)
+
+func (f KeyFunc) Key() ([]byte, error) {
+	return f()
+}
+
+// secret is the cipher text of a secret value, setKind stores it as kind "secret".
+type secret string
+
+const secretMask = "******"
+
+const sealMagic = "TREEAES\x01"
+
+func parseKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		key = []byte(s)
+	}
+
+	switch len(key) {
+	case 16, 24, 32:
+		return key, nil
+	}
+	return nil, fmt.Errorf("key size is incorrect: %v", len(key))
+}
+
+// EnvKey reads a base64 encoded key from the environment variable.
+func EnvKey(name string) KeyProvider {
+	return KeyFunc(func() ([]byte, error) {
+		s, ok := os.LookupEnv(name)
+		if !ok {
+			return nil, fmt.Errorf("not found key: $%v", name)
+		}
+		return parseKey(s)
+	})
+}
+
+// FileKey reads a base64 encoded key from the file.
+func FileKey(fileName string) KeyProvider {
+	return KeyFunc(func() ([]byte, error) {
+		buf, err := os.ReadFile(fileName)
+		if err != nil {
+			return nil, err
+		}
+		return parseKey(string(buf))
+	})
+}
+
+func (root *Tree) aead() (cipher.AEAD, error) {
+	if root.Keys == nil {
+		return nil, fmt.Errorf("key provider is NULL")
+	}
+
+	key, err := root.Keys.Key()
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (root *Tree) encrypt(plain []byte) ([]byte, error) {
+	gcm, err := root.aead()
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plain, nil), nil
+}
+
+func (root *Tree) decrypt(data []byte) ([]byte, error) {
+	gcm, err := root.aead()
+	if err != nil {
+		return nil, err
+	}
+
+	n := gcm.NonceSize()
+	if len(data) < n {
+		return nil, fmt.Errorf("cipher text is too short")
+	}
+
+	return gcm.Open(nil, data[:n], data[n:], nil)
+}
+
+func (root *Tree) seal(fileName string, buf []byte) ([]byte, error) {
+	buf, err := compress(fileName, buf, root.CompressLevel)
+	if (err != nil) || !root.EncryptFile {
+		return buf, err
+	}
+
+	buf, err = root.encrypt(buf)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(sealMagic), buf...), nil
+}
+
+func (root *Tree) unseal(data []byte) ([]byte, error) {
+	if bytes.HasPrefix(data, []byte(sealMagic)) {
+		var err error
+		data, err = root.decrypt(data[len(sealMagic):])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return decompress(data)
+}
+
+// reveal returns a string twig holding the plain text of a secret twig.
+func (root *Tree) reveal(tw *twig) (*twig, error) {
+	if tw.Kind != "secret" {
+		return tw, nil
+	}
+
+	s, _ := tw.Value.(string)
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	plain, err := root.decrypt(data)
+	if err != nil {
+		return nil, fmt.Errorf("can not decrypt: %v: %v", tw.Name, err)
+	}
+
+	return &twig{Name: tw.Name, Kind: "string", Value: string(plain)}, nil
+}
+
+func (root *Tree) sealValue(value string) (secret, error) {
+	data, err := root.encrypt([]byte(value))
+	if err != nil {
+		return "", err
+	}
+	return secret(base64.StdEncoding.EncodeToString(data)), nil
+}
+
+func (root *Tree) SetSecret(value string, src []string) error {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	return root.setSecret(value, src)
+}
+
+func (root *Tree) setSecret(value string, src []string) error {
+	s, err := root.sealValue(value)
+	if err != nil {
+		return err
+	}
+	return root.setValue(s, src)
+}
+
+func (root *Tree) AddSecret(name string, value string, dst []string) error {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	return root.addSecret(name, value, dst)
+}
+
+func (root *Tree) addSecret(name string, value string, dst []string) error {
+	s, err := root.sealValue(value)
+	if err != nil {
+		return err
+	}
+	return root.addNew(name, s, dst)
+}
@@ -0,0 +1,351 @@
+package tree
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// lazyRef points at the "childs" array of a node in the tree file,
+// root expands the include nodes among them.
+type lazyRef struct {
+	root   *Tree
+	file   string
+	offset int64
+}
+
+type loader struct {
+	root   *Tree
+	dec    *json.Decoder
+	file   string
+	prefix []string
+	depth  int
+}
+
+// loadMu guards the lazy childs, readers under View load them on first access.
+var loadMu sync.RWMutex
+
+func (tw *twig) load() error {
+	loadMu.RLock()
+	lazy := tw.lazy
+	loadMu.RUnlock()
+	if lazy == nil {
+		return nil
+	}
+
+	loadMu.Lock()
+	defer loadMu.Unlock()
+
+	if tw.lazy == nil {
+		return nil
+	}
+
+	f, err := os.Open(tw.lazy.file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Seek(tw.lazy.offset, 0)
+	if err != nil {
+		return err
+	}
+
+	// skip the ':' between the key and the array
+	r := bufio.NewReader(f)
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if (c != ':') && (c != ' ') && (c != '\t') && (c != '\r') && (c != '\n') {
+			r.UnreadByte()
+			break
+		}
+	}
+
+	var w twig
+	err = json.NewDecoder(r).Decode(&w.Childs)
+	if err != nil {
+		return err
+	}
+
+	if tw.lazy.root != nil {
+		file := tw.lazy.file
+		err = tw.lazy.root.expand(&w, filepath.Dir(file), map[string]bool{file: true}, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	tw.Childs = w.Childs
+	tw.lazy = nil
+	tw.unindex()
+	return nil
+}
+
+func (tw *twig) loadAll() error {
+	err := tw.load()
+	if err != nil {
+		return err
+	}
+
+	for i := range tw.Childs {
+		err = tw.Childs[i].loadAll()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ld *loader) skip() error {
+	var depth int
+
+	for {
+		t, err := ld.dec.Token()
+		if err != nil {
+			return err
+		}
+
+		switch t {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+func (ld *loader) keep(names []string) bool {
+	for i := range names {
+		if i >= len(ld.prefix) {
+			return true
+		}
+		if names[i] != ld.prefix[i] {
+			// options are needed by Open
+			return (i == 0) && (names[0] == "options")
+		}
+	}
+	return true
+}
+
+// node reads one twig object, a node outside of the prefix is skipped and nil is returned.
+func (ld *loader) node(parent []string, top bool) (*twig, error) {
+	t, err := ld.dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, nil
+	}
+	if t != json.Delim('{') {
+		return nil, fmt.Errorf("format is incorrect. %v", t)
+	}
+
+	tw := &twig{}
+	names := parent
+	keep := true
+	for ld.dec.More() {
+		t, err = ld.dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := t.(string)
+
+		if !keep {
+			err = ld.skip()
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		switch key {
+		case "name":
+			err = ld.dec.Decode(&tw.Name)
+			if !top {
+				names = appendName(parent, tw.Name)
+				keep = ld.keep(names)
+			}
+		case "kind":
+			err = ld.dec.Decode(&tw.Kind)
+		case "value":
+			err = ld.dec.Decode(&tw.Value)
+		case "desc":
+			err = ld.dec.Decode(&tw.Desc)
+		case "childs":
+			if (ld.depth > 0) && (len(names) >= ld.depth) {
+				tw.lazy = &lazyRef{root: ld.root, file: ld.file, offset: ld.dec.InputOffset()}
+				err = ld.skip()
+				break
+			}
+			err = ld.childs(tw, names)
+		default:
+			err = ld.skip()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = ld.dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	if !keep {
+		return nil, nil
+	}
+	return tw, nil
+}
+
+func (ld *loader) childs(tw *twig, names []string) error {
+	t, err := ld.dec.Token()
+	if err != nil {
+		return err
+	}
+	if t == nil {
+		return nil
+	}
+	if t != json.Delim('[') {
+		return fmt.Errorf("format is incorrect. %v", t)
+	}
+
+	for ld.dec.More() {
+		ctw, err := ld.node(names, false)
+		if err != nil {
+			return err
+		}
+		if ctw != nil {
+			tw.Childs = append(tw.Childs, *ctw)
+		}
+	}
+
+	_, err = ld.dec.Token()
+	return err
+}
+
+func (root *Tree) stream(fileName string, prefix []string, depth int) error {
+	fileName, err := fullPath(fileName)
+	if err != nil {
+		return err
+	}
+
+	// the journal may change nodes outside of the prefix, they can not be replayed
+	if len(prefix) != 0 {
+		fi, err := os.Stat(fileName + ".wal")
+		if (err == nil) && (fi.Size() > 0) {
+			return fmt.Errorf("journal is not empty, open the whole file: %v", fileName)
+		}
+	}
+
+	f, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	br := bufio.NewReader(io.TeeReader(f, h))
+	var r io.Reader = br
+
+	head, _ := br.Peek(len(sealMagic))
+	if bytes.HasPrefix(head, []byte(sealMagic)) {
+		return fmt.Errorf("streaming needs an unencrypted file: %v", fileName)
+	}
+
+	c, err := findCompressor("", head)
+	if err != nil {
+		return err
+	}
+	if c != nil {
+		if depth > 0 {
+			return fmt.Errorf("lazy loading needs an uncompressed file: %v", fileName)
+		}
+
+		cr, err := c.Reader(r)
+		if err != nil {
+			return err
+		}
+		defer cr.Close()
+		r = cr
+	}
+
+	ld := loader{
+		root:   root,
+		dec:    json.NewDecoder(r),
+		file:   fileName,
+		prefix: prefix,
+		depth:  depth,
+	}
+
+	base, err := ld.node(nil, true)
+	if err != nil {
+		return err
+	}
+
+	// hash the rest of the file as well
+	_, err = io.Copy(io.Discard, br)
+	if err != nil {
+		return err
+	}
+	err = root.verify(fileName, h.Sum(nil))
+	if err != nil {
+		return err
+	}
+
+	err = root.expand(base, filepath.Dir(fileName), map[string]bool{fileName: true}, false)
+	if err != nil {
+		return err
+	}
+
+	root.Base = base
+	root.version++
+	root.fileName = fileName
+	root.journaled = 0
+	root.partial = len(prefix) != 0
+	root.binary = false
+
+	root.Indent, err = root.GetValueStr([]string{"options", "indent"})
+	if err != nil {
+		return err
+	}
+
+	err = root.replay()
+	if err != nil {
+		return err
+	}
+
+	if root.hist != nil {
+		root.hist.reset()
+	}
+	root.markSaved()
+	return nil
+}
+
+// OpenPrefix loads only the nodes on the prefix path and the subtree below it.
+// The result is read-only on disk, Save reports an error.
+func (root *Tree) OpenPrefix(fileName string, prefix []string) error {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	return root.stream(fileName, prefix, 0)
+}
+
+// OpenLazy loads the tree down to depth, deeper childs are read on first access.
+func (root *Tree) OpenLazy(fileName string, depth int) error {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	return root.stream(fileName, nil, depth)
+}
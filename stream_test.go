@@ -0,0 +1,49 @@
+package tree
+
+import (
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestOpenLazyConcurrent(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "lazy.json")
+
+	src := Tree{}
+	err := src.Create(fileName, "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.AddNew("a", nil, nil)
+	src.AddNew("b", nil, []string{"a"})
+	for i := 0; i < 4; i++ {
+		src.AddNew("n"+strconv.Itoa(i), int64(i), []string{"a", "b"})
+	}
+	err = src.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var root Tree
+	err = root.OpenLazy(fileName, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			root.View(func(r *Tree) error {
+				v, err := r.GetValueInt([]string{"a", "b", "n" + strconv.Itoa(i)})
+				if (err != nil) || (v != int64(i)) {
+					t.Errorf("%v: %v %v", i, v, err)
+				}
+				return nil
+			})
+		}(i)
+	}
+	wg.Wait()
+}
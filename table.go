@@ -0,0 +1,538 @@
+package tree
+
+import (
+	"fmt"
+)
+
+// Table works on the layout of SetMapings: every child of the table node is a row
+// named by its primary key and holds one child per column.
+type Table struct {
+	root    *Tree
+	path    []string
+	key     string
+	keyKind string
+	columns []Column
+	indexes map[string]*tableIndex
+}
+
+// Column declares a column, an empty Kind accepts any kind.
+type Column struct {
+	Name string
+	Kind string
+}
+
+type tableIndex struct {
+	version uint64
+	rows    map[string][]string
+}
+
+// Table binds src as a table, it is created when it does not exist.
+// key is the primary key column, "name" for files written by SetMapings.
+func (root *Tree) Table(src []string, key string, columns ...Column) (*Table, error) {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	if key == "" {
+		key = "name"
+	}
+
+	_, err := root.Find(src)
+	if (err != nil) && (len(src) != 0) {
+		err = root.addNew(src[len(src)-1], nil, src[:len(src)-1])
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Table{root: root, path: appendName(src), key: key, indexes: make(map[string]*tableIndex)}
+	for _, c := range columns {
+		if c.Name == key {
+			t.keyKind = c.Kind
+		} else {
+			t.columns = append(t.columns, c)
+		}
+	}
+	return t, nil
+}
+
+func (t *Table) column(name string) (Column, bool) {
+	for _, c := range t.columns {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return Column{}, len(t.columns) == 0
+}
+
+// check validates m and returns the row name.
+func (t *Table) check(m map[string]any) (string, error) {
+	v, ok := m[t.key]
+	if !ok || (v == nil) {
+		return "", fmt.Errorf("key is null: %v", t.key)
+	}
+
+	var w twig
+	err := w.setKind(v)
+	if err != nil {
+		return "", err
+	}
+	name := w.get("string").(string)
+	if name == "" {
+		return "", fmt.Errorf("key is null: %v", t.key)
+	}
+
+	for k, v := range m {
+		if k == t.key {
+			continue
+		}
+
+		c, ok := t.column(k)
+		if !ok {
+			return "", fmt.Errorf("not found column: %v", k)
+		}
+
+		err = w.setKind(v)
+		if err != nil {
+			return "", err
+		}
+		if (c.Kind != "") && (w.Kind != "") && (w.Kind != c.Kind) {
+			return "", fmt.Errorf("do not match kind: %v", k)
+		}
+	}
+
+	return name, nil
+}
+
+func (t *Table) Insert(m map[string]any) error {
+	t.root.mu.Lock()
+	defer t.root.mu.Unlock()
+
+	return t.insert(m)
+}
+
+func (t *Table) insert(m map[string]any) error {
+	name, err := t.check(m)
+	if err != nil {
+		return err
+	}
+
+	tw, err := t.root.Find(t.path)
+	if err != nil {
+		return err
+	}
+	if tw.child(name) >= 0 {
+		return fmt.Errorf("already exists: %v", name)
+	}
+
+	old, err := t.keys(name)
+	if err != nil {
+		return err
+	}
+
+	err = t.addRow(name, m)
+	if err != nil {
+		return err
+	}
+	return t.reindex(name, old)
+}
+
+func (t *Table) addRow(name string, m map[string]any) error {
+	root := t.root
+	root.beginGroup()
+	defer root.endGroup()
+
+	dst := appendName(t.path, name)
+	err := root.addNew(name, nil, t.path)
+	if err != nil {
+		return err
+	}
+
+	// declared columns keep their order, missing ones are null
+	for _, c := range t.columns {
+		err = root.addNew(c.Name, m[c.Name], dst)
+		if err != nil {
+			return err
+		}
+	}
+	if len(t.columns) == 0 {
+		for k, v := range m {
+			if k == t.key {
+				continue
+			}
+			err = root.addNew(k, v, dst)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Update sets the columns of m on the row named by its key.
+func (t *Table) Update(m map[string]any) error {
+	t.root.mu.Lock()
+	defer t.root.mu.Unlock()
+
+	return t.update(m)
+}
+
+func (t *Table) update(m map[string]any) error {
+	name, err := t.check(m)
+	if err != nil {
+		return err
+	}
+
+	old, err := t.keys(name)
+	if err != nil {
+		return err
+	}
+
+	err = t.setRow(name, m)
+	if err != nil {
+		return err
+	}
+	return t.reindex(name, old)
+}
+
+func (t *Table) setRow(name string, m map[string]any) error {
+	dst := appendName(t.path, name)
+	tw, err := t.root.Find(dst)
+	if err != nil {
+		return err
+	}
+
+	root := t.root
+	root.beginGroup()
+	defer root.endGroup()
+
+	for k, v := range m {
+		if k == t.key {
+			continue
+		}
+
+		if tw.child(k) < 0 {
+			err = root.addNew(k, v, dst)
+		} else {
+			err = root.setValue(v, appendName(dst, k))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *Table) Upsert(m map[string]any) error {
+	t.root.mu.Lock()
+	defer t.root.mu.Unlock()
+
+	name, err := t.check(m)
+	if err != nil {
+		return err
+	}
+
+	_, err = t.root.Find(appendName(t.path, name))
+	if err != nil {
+		return t.insert(m)
+	}
+	return t.update(m)
+}
+
+func (t *Table) Get(key string) (map[string]any, error) {
+	tw, err := t.root.Find(appendName(t.path, key))
+	if err != nil {
+		return nil, err
+	}
+	return t.maping(tw)
+}
+
+// maping returns the row tw with the key typed by the kind of the key column.
+func (t *Table) maping(tw *twig) (map[string]any, error) {
+	m, err := t.root.maping(tw, t.key)
+	if err != nil {
+		return nil, err
+	}
+
+	m[t.key], err = parseKind(t.keyKind, tw.Name)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// DeleteWhere deletes the rows matching the predicate (see Query) and returns their count.
+func (t *Table) DeleteWhere(where string) (int, error) {
+	t.root.mu.Lock()
+	defer t.root.mu.Unlock()
+
+	items, err := t.rows(where)
+	if err != nil {
+		return 0, err
+	}
+
+	var names []string
+	var olds []map[string]string
+	for i := range items {
+		name := items[i].path[len(items[i].path)-1]
+		old, err := t.keys(name)
+		if err != nil {
+			return 0, err
+		}
+		names = append(names, name)
+		olds = append(olds, old)
+	}
+
+	n, err := t.deleteRows(items)
+	if err != nil {
+		return n, err
+	}
+
+	for i := range names {
+		err = t.reindex(names[i], olds[i])
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+func (t *Table) deleteRows(items []queryItem) (int, error) {
+	root := t.root
+	root.beginGroup()
+	defer root.endGroup()
+
+	for i := range items {
+		err := root.deleteNode(items[i].path)
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(items), nil
+}
+
+// Select returns the rows matching the predicate, all rows if where is empty.
+// order lists columns, "-" in front sorts descending.
+func (t *Table) Select(where string, order ...string) ([]map[string]any, error) {
+	items, err := t.rows(where, order...)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []map[string]any
+	for i := range items {
+		m, err := t.maping(items[i].tw)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, m)
+	}
+	return result, nil
+}
+
+func (t *Table) rows(where string, order ...string) ([]queryItem, error) {
+	seg := querySeg{pattern: "*"}
+	if where != "" {
+		pred, err := parseExpr(t.where(where))
+		if err != nil {
+			return nil, err
+		}
+		seg.pred = pred
+	}
+
+	qq := &query{segs: []querySeg{seg}}
+	for _, o := range order {
+		s := querySort{field: o}
+		if (len(o) > 1) && (o[0] == '-') {
+			s = querySort{field: o[1:], desc: true}
+		}
+		if s.field == t.key {
+			s.field = "name"
+		}
+		qq.sorts = append(qq.sorts, s)
+	}
+
+	tw, err := t.root.Find(t.path)
+	if err != nil {
+		return nil, err
+	}
+	return t.root.queryFrom([]queryItem{{tw: tw, path: t.path}}, qq)
+}
+
+// where maps the key column to the node name used by predicates.
+func (t *Table) where(s string) string {
+	if t.key == "name" {
+		return s
+	}
+
+	toks, err := lexExpr(s)
+	if err != nil {
+		return s
+	}
+
+	var result string
+	for _, tok := range toks {
+		switch {
+		case (tok.kind == "field") && (tok.text == t.key):
+			result += " name"
+		case tok.kind == "string":
+			result += fmt.Sprintf(" %q", tok.text)
+		default:
+			result += " " + tok.text
+		}
+	}
+	return result
+}
+
+// CreateIndex adds a secondary index on the column, used by Lookup.
+func (t *Table) CreateIndex(column string) error {
+	if _, ok := t.column(column); !ok && (column != t.key) {
+		return fmt.Errorf("not found column: %v", column)
+	}
+
+	t.indexes[column] = &tableIndex{}
+	return nil
+}
+
+// Lookup returns the rows whose column equals value.
+// It uses the index of the column, which Insert, Update and DeleteWhere keep up to date.
+// Other changes of the tree drop the index, it is rebuilt by the next Lookup.
+func (t *Table) Lookup(column string, value any) ([]map[string]any, error) {
+	var w twig
+	err := w.setKind(value)
+	if err != nil {
+		return nil, err
+	}
+
+	idx, ok := t.indexes[column]
+	if !ok {
+		return t.Select(fmt.Sprintf("%v == %q", column, w.get("string")))
+	}
+
+	tw, err := t.root.Find(t.path)
+	if err != nil {
+		return nil, err
+	}
+
+	if (idx.rows == nil) || (idx.version != t.root.version) {
+		idx.rows = make(map[string][]string)
+		for i := range tw.Childs {
+			rtw := &tw.Childs[i]
+			k, ok, err := t.rowKey(column, rtw)
+			if err != nil {
+				idx.rows = nil
+				return nil, err
+			}
+			if ok {
+				idx.rows[k] = append(idx.rows[k], rtw.Name)
+			}
+		}
+		idx.version = t.root.version
+	}
+
+	if column == t.key {
+		w.setKind(w.get("string"))
+	}
+
+	var result []map[string]any
+	for _, name := range idx.rows[indexKey(&w)] {
+		m, err := t.Get(name)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, m)
+	}
+	return result, nil
+}
+
+// rowKey is the index key of column in the row tw, ok is false when the row has no such column.
+func (t *Table) rowKey(column string, tw *twig) (string, bool, error) {
+	if column == t.key {
+		return indexKey(&twig{Kind: "string", Value: tw.Name}), true, nil
+	}
+
+	j := tw.child(column)
+	if j < 0 {
+		return "", false, nil
+	}
+	ctw, err := t.root.reveal(&tw.Childs[j])
+	if err != nil {
+		return "", false, err
+	}
+	return indexKey(ctw), true, nil
+}
+
+// keys returns the index keys of the row name before a change.
+// Indexes that missed a change of the tree are dropped.
+func (t *Table) keys(name string) (map[string]string, error) {
+	result := make(map[string]string)
+
+	tw, err := t.root.Find(appendName(t.path, name))
+	for column, idx := range t.indexes {
+		if idx.version != t.root.version {
+			idx.rows = nil
+		}
+		if (idx.rows == nil) || (err != nil) {
+			continue
+		}
+
+		k, ok, err := t.rowKey(column, tw)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			result[column] = k
+		}
+	}
+	return result, nil
+}
+
+// reindex moves the row name from its keys before the change, old, to its current ones.
+func (t *Table) reindex(name string, old map[string]string) error {
+	tw, err := t.root.Find(appendName(t.path, name))
+	for column, idx := range t.indexes {
+		if idx.rows == nil {
+			continue
+		}
+
+		k, ok := "", false
+		if err == nil {
+			k, ok, err = t.rowKey(column, tw)
+			if err != nil {
+				idx.rows = nil
+				return err
+			}
+		}
+
+		o, had := old[column]
+		if had && (!ok || (o != k)) {
+			idx.rows[o] = removeName(idx.rows[o], name)
+			if len(idx.rows[o]) == 0 {
+				delete(idx.rows, o)
+			}
+		}
+		if ok && (!had || (o != k)) {
+			idx.rows[k] = append(idx.rows[k], name)
+		}
+		idx.version = t.root.version
+	}
+	return nil
+}
+
+func removeName(names []string, name string) []string {
+	for i := range names {
+		if names[i] == name {
+			return append(names[:i:i], names[i+1:]...)
+		}
+	}
+	return names
+}
+
+func indexKey(tw *twig) string {
+	kind := tw.Kind
+	if (kind == "integer") || (kind == "float") {
+		kind = "float"
+	}
+	return kind + "\x00" + tw.get("string").(string)
+}
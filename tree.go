@@ -1,6 +1,8 @@
 package tree
 
 import (
+	"crypto/ed25519"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -8,6 +10,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -17,13 +20,57 @@ type twig struct {
 	Name   string `json:"name"`
 	Kind   string `json:"kind"`
 	Value  any    `json:"value"`
+	Desc   string `json:"desc,omitempty"`
 	Childs []twig `json:"childs"`
+
+	index   map[string]int
+	indexed int
+	lazy    *lazyRef
+	inc     *include
 }
 
 type Tree struct {
 	Base     *twig
 	fileName string
 	Indent   string
+
+	Backups     int
+	BackupStyle BackupStyle
+
+	Journal      bool
+	JournalLimit int
+
+	// CompressLevel is passed to the compressor, e.g. gzip.BestSpeed,
+	// 0 selects its default level and CompressNone stores without compression.
+	CompressLevel int
+
+	Keys        KeyProvider
+	EncryptFile bool
+
+	Checksum  bool
+	SignKey   ed25519.PrivateKey
+	VerifyKey ed25519.PublicKey
+	Verify    VerifyPolicy
+	Warn      func(err error)
+
+	Redact []string
+
+	ResolveRefs bool
+
+	// mu is held by the mutators, readers share it through View
+	mu      sync.RWMutex
+	hist    *history
+	version uint64
+	saved   uint64
+
+	journaled int
+	replaying bool
+	partial   bool
+	binary    bool
+
+	watchMu  sync.Mutex
+	watchers map[int]func(o Operation)
+	watchID  int
 }
 
 const treeLayout = "2006-01-02T15:04:05.0000000-07:00"
@@ -46,6 +93,10 @@ func fullPath(fileName string) (string, error) {
 	return fileName, nil
 }
 
+func appendName(names []string, name ...string) []string {
+	return append(append([]string{}, names...), name...)
+}
+
 func (tw *twig) set(name string, value any) error {
 	tw.Name = name
 	tw.setKind(value)
@@ -53,6 +104,24 @@ func (tw *twig) set(name string, value any) error {
 	return nil
 }
 
+func (tw *twig) clone() (twig, error) {
+	w := twig{Name: tw.Name, Kind: tw.Kind, Value: tw.Value, Desc: tw.Desc, inc: tw.inc}
+
+	err := tw.load()
+	if err != nil {
+		return w, err
+	}
+
+	for i := range tw.Childs {
+		c, err := tw.Childs[i].clone()
+		if err != nil {
+			return w, err
+		}
+		w.Childs = append(w.Childs, c)
+	}
+	return w, nil
+}
+
 func (tw *twig) setKind(value any) error {
 	switch x := value.(type) {
 	case string:
@@ -85,6 +154,15 @@ func (tw *twig) setKind(value any) error {
 	case bool:
 		tw.Value = x
 		tw.Kind = "bool"
+	case secret:
+		tw.Value = string(x)
+		tw.Kind = "secret"
+	case Ref:
+		tw.Value = string(x)
+		tw.Kind = "ref"
+	case Include:
+		tw.Value = string(x)
+		tw.Kind = "include"
 	case nil:
 		tw.Value = nil
 		tw.Kind = ""
@@ -97,6 +175,45 @@ func (tw *twig) setKind(value any) error {
 	return nil
 }
 
+func parseKind(kind string, s string) (any, error) {
+	switch strings.ToLower(kind) {
+	case "", "string":
+		return s, nil
+	case "integer":
+		return strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	case "float":
+		return strconv.ParseFloat(strings.TrimSpace(s), 64)
+	case "bool":
+		return strconv.ParseBool(strings.TrimSpace(s))
+	case "datetime":
+		s = strings.TrimSpace(s)
+		tt, err := time.Parse(treeLayout, s)
+		if err != nil {
+			tt, err = time.Parse(time.RFC3339Nano, s)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return tt, nil
+	case "secret":
+		return nil, fmt.Errorf("secret is set by SetSecret")
+	case "ref":
+		return Ref(strings.TrimSpace(s)), nil
+	case "include":
+		return Include(strings.TrimSpace(s)), nil
+	}
+
+	return nil, fmt.Errorf("not found kind: %v", kind)
+}
+
+// ParseValue converts the text to the Go type of the kind, "null" gives nil.
+func ParseValue(kind string, s string) (any, error) {
+	if strings.ToLower(kind) == "null" {
+		return nil, nil
+	}
+	return parseKind(kind, s)
+}
+
 func (tw *twig) get(kind ...string) any {
 	var result any
 	var k string
@@ -120,6 +237,8 @@ func (tw *twig) get(kind ...string) any {
 
 	case "datetime":
 
+	case "ref", "include":
+
 	default:
 		return nil
 	}
@@ -215,6 +334,8 @@ func (tw *twig) get(kind ...string) any {
 		default:
 			return result
 		}
+	case "ref", "include":
+		result, _ = tw.Value.(string)
 	default:
 		result = nil
 	}
@@ -222,6 +343,9 @@ func (tw *twig) get(kind ...string) any {
 }
 
 func (root *Tree) Create(fileName string, indent string) error {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
 	var rt twig
 	var op twig
 	var id twig
@@ -232,82 +356,128 @@ func (root *Tree) Create(fileName string, indent string) error {
 	op.Childs = append(op.Childs, id)
 	rt.Childs = append(rt.Childs, op)
 	root.Base = &rt
+	root.version++
 
 	fileName, err := fullPath(fileName)
 	if err != nil {
 		return err
 	}
 
-	_, err = os.Stat(fileName)
-	if err == nil {
-		err = os.Remove(fileName)
-		if err != nil {
-			return err
-		}
-	}
-
-	buf, _ := json.MarshalIndent(root.Base, "", indent)
-	f, err := os.Create(fileName)
+	root.Indent = indent
+	root.partial = false
+	root.binary = false
+	err = root.write(fileName)
 	if err != nil {
 		return err
 	}
-	f.Write(buf)
-	f.Close()
 
 	root.fileName = fileName
-	root.Indent = indent
+	err = root.truncateJournal()
+	if err != nil {
+		return err
+	}
+	if root.hist != nil {
+		root.hist.reset()
+	}
+	root.markSaved()
 	return nil
 }
 
 func (root *Tree) Open(fileName string) error {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	return root.open(fileName)
+}
+
+func (root *Tree) open(fileName string) error {
 	fileName, err := fullPath(fileName)
 	if err != nil {
 		return err
 	}
 
-	_, err = os.Stat(fileName)
+	base, err := root.read(fileName, true)
 	if err != nil {
 		return err
 	}
 
-	f, err := os.Open(fileName)
+	err = root.expand(base, filepath.Dir(fileName), map[string]bool{fileName: true}, false)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
 
-	var jsonData []byte
-	jsonData, err = io.ReadAll(f)
-	if err != nil {
-		return err
-	}
+	root.Base = base
+	root.version++
+	root.fileName = fileName
+	root.journaled = 0
+	root.partial = false
+	root.binary = false
 
-	err = json.Unmarshal(jsonData, &root.Base)
+	root.Indent, err = root.GetValueStr([]string{"options", "indent"})
 	if err != nil {
 		return err
 	}
 
-	root.fileName = fileName
-
-	root.Indent, err = root.GetValueStr([]string{"options", "indent"})
+	err = root.replay()
 	if err != nil {
 		return err
 	}
 
+	if root.hist != nil {
+		root.hist.reset()
+	}
+	root.markSaved()
 	return nil
 }
 
 func (root *Tree) Close() {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	root.close()
+}
+
+func (root *Tree) close() {
+	// on error the journal is kept and replayed by the next Open
+	if root.Journal && (root.journaled > 0) {
+		root.compact()
+	}
+
 	root.Base = nil
+	root.version++
 	root.fileName = ""
 	root.Indent = ""
+	root.hist = nil
+	root.partial = false
+	root.binary = false
+}
+
+func (root *Tree) clone() (*Tree, error) {
+	var base *twig
+	if root.Base != nil {
+		b, err := root.Base.clone()
+		if err != nil {
+			return nil, err
+		}
+		base = &b
+	}
+
+	return &Tree{Base: base, fileName: root.fileName, Indent: root.Indent}, nil
 }
 
 func (root *Tree) Reload() error {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	return root.reload()
+}
+
+func (root *Tree) reload() error {
 	fileName := root.fileName
 	root.Base = nil
+	root.version++
 
-	err := root.Open(fileName)
+	err := root.open(fileName)
 	if err != nil {
 		return err
 	}
@@ -315,6 +485,9 @@ func (root *Tree) Reload() error {
 }
 
 func (root *Tree) SaveAs(fileName string) error {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
 	dir, fn := filepath.Split(fileName)
 	if dir == "./" {
 		exeFull, _ := os.Executable()
@@ -327,113 +500,175 @@ func (root *Tree) SaveAs(fileName string) error {
 		return err
 	}
 
-	_, err = os.Stat(fileName)
-	if err == nil {
-		err = os.Remove(fileName)
-		if err != nil {
-			return err
-		}
-	}
+	return root.write(fileName)
+}
 
-	var buf []byte
-	if len(root.Indent) == 0 {
-		buf, _ = json.Marshal(root.Base)
-	} else {
-		buf, _ = json.MarshalIndent(root.Base, "", root.Indent)
-	}
+func (root *Tree) Save() error {
+	root.mu.Lock()
+	defer root.mu.Unlock()
 
-	f, err := os.Create(fileName)
+	return root.save()
+}
+
+func (root *Tree) save() error {
+	err := root.write(root.fileName)
 	if err != nil {
 		return err
 	}
 
-	f.Write(buf)
-	f.Close()
+	err = root.truncateJournal()
+	if err != nil {
+		return err
+	}
 
+	root.markSaved()
 	return nil
 }
 
-func (root *Tree) Save() error {
-	_, err := os.Stat(root.fileName)
-	if err == nil {
-		err = os.Remove(root.fileName)
+func (root *Tree) read(fileName string, verify bool) (*twig, error) {
+	var base *twig
+
+	_, err := os.Stat(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var jsonData []byte
+	jsonData, err = io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	if verify {
+		sum := sha256.Sum256(jsonData)
+		err = root.verify(fileName, sum[:])
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
-	var buf []byte
-	if len(root.Indent) == 0 {
-		buf, _ = json.Marshal(root.Base)
-	} else {
-		buf, _ = json.MarshalIndent(root.Base, "", root.Indent)
+	jsonData, err = root.unseal(jsonData)
+	if err != nil {
+		return nil, err
 	}
 
-	f, err := os.Create(root.fileName)
+	err = json.Unmarshal(jsonData, &base)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	f.Write(buf)
-	f.Close()
-
-	return nil
+	return base, nil
 }
 
-func (root *Tree) Find(names []string) (*twig, error) {
-	var count int = 0
+func (root *Tree) write(fileName string) error {
+	var buf []byte
+	var err error
 
-	if root.Base == nil {
-		return nil, fmt.Errorf("root is NULL: %v", names)
+	if root.partial {
+		return fmt.Errorf("tree is partially loaded: %v", root.fileName)
 	}
 
-	r := root.Base
-	for i := range names {
-		for j := range r.Childs {
-			//log.Printf(".name: %v", names[i])
+	if root.Base != nil {
+		err = root.Base.loadAll()
+		if err != nil {
+			return err
+		}
+	}
 
-			if r.Childs[j].Name == names[i] {
-				r = &r.Childs[j]
-				count++
-				break
-			}
+	base, err := root.export()
+	if err != nil {
+		return err
+	}
+
+	if root.Base != nil {
+		err = root.storeIncludes(root.Base, filepath.Dir(fileName))
+		if err != nil {
+			return err
 		}
 	}
 
-	if count == len(names) {
-		return r, nil
+	if root.binary {
+		buf, err = root.MarshalBinary()
+	} else if len(root.Indent) == 0 {
+		buf, err = json.Marshal(base)
+	} else {
+		buf, err = json.MarshalIndent(base, "", root.Indent)
+	}
+	if err != nil {
+		return err
 	}
 
-	return nil, fmt.Errorf("not found: %v", names[count])
+	buf, err = root.seal(fileName, buf)
+	if err != nil {
+		return err
+	}
+
+	return root.store(fileName, buf)
 }
 
-func (root *Tree) findPlus(names []string) (*twig, *twig, error) {
-	var count int
+// writeFile writes a temporary file and renames it,
+// so a crash never leaves a half written file behind.
+func writeFile(fileName string, buf []byte) error {
+	var mode os.FileMode = 0644
 
-	if root.Base == nil {
-		return nil, nil, fmt.Errorf("root is NULL: %v", names)
+	fi, err := os.Stat(fileName)
+	if err == nil {
+		mode = fi.Mode().Perm()
 	}
 
-	r := root.Base
-	v := root.Base
-	for i := range names {
-		for j := range r.Childs {
-			//log.Printf(".name: %v", names[i])
+	dir, fn := filepath.Split(fileName)
+	f, err := os.CreateTemp(dir, fn+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
 
-			if r.Childs[j].Name == names[i] {
-				v = r
-				r = &r.Childs[j]
-				count++
-				break
-			}
-		}
+	_, err = f.Write(buf)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, mode)
+	}
+	if err == nil {
+		err = os.Rename(tmp, fileName)
 	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return nil
+}
 
-	if count == len(names) {
-		return r, v, nil
+// Find returns the internal node, the pointer is invalidated by mutations of the tree.
+//
+// Deprecated: use Node.
+func (root *Tree) Find(names []string) (*twig, error) {
+	r, _, err := root.resolve(names, true, nil)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil, nil, fmt.Errorf("not found: %v", names[count])
+	err = r.load()
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// findPlus returns the node and its parent, a ref at the end is not followed.
+func (root *Tree) findPlus(names []string) (*twig, *twig, error) {
+	return root.resolve(names, false, nil)
 }
 
 func (root *Tree) List(names []string) ([]string, error) {
@@ -452,6 +687,13 @@ func (root *Tree) List(names []string) ([]string, error) {
 }
 
 func (root *Tree) AddNew(name string, value any, dst []string) error {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	return root.addNew(name, value, dst)
+}
+
+func (root *Tree) addNew(name string, value any, dst []string) error {
 	if name == "" {
 		return fmt.Errorf(".Name blank.%v", name)
 	}
@@ -461,10 +703,8 @@ func (root *Tree) AddNew(name string, value any, dst []string) error {
 		return err
 	}
 
-	for i := range fw.Childs {
-		if fw.Childs[i].Name == name {
-			return fmt.Errorf("duplicate name. %v", name)
-		}
+	if fw.child(name) >= 0 {
+		return fmt.Errorf("duplicate name. %v", name)
 	}
 
 	var tw twig
@@ -473,11 +713,24 @@ func (root *Tree) AddNew(name string, value any, dst []string) error {
 		return err
 	}
 
-	fw.Childs = append(fw.Childs, tw)
-	return nil
+	fw.add(tw)
+
+	dst = appendName(dst)
+	return root.record(func(root *Tree) error {
+		return root.deleteNode(appendName(dst, name))
+	}, func(root *Tree) error {
+		return root.addNew(name, value, dst)
+	}, Operation{Op: "add", Path: appendName(dst, name), Value: tw.Value, Kind: tw.Kind})
 }
 
 func (root *Tree) Delete(dst []string) error {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	return root.deleteNode(dst)
+}
+
+func (root *Tree) deleteNode(dst []string) error {
 	var _delete func(t *twig) error
 
 	_delete = func(t *twig) error {
@@ -493,10 +746,17 @@ func (root *Tree) Delete(dst []string) error {
 		return err
 	}
 
+	index := tr.child(td.Name)
+	saved, err := td.clone()
+	if err != nil {
+		return err
+	}
+
 	for i := range td.Childs {
 		_delete(&td.Childs[i])
 	}
 	td.Childs = nil
+	td.unindex()
 
 	var tw twig
 	tw.Childs = tr.Childs
@@ -506,19 +766,59 @@ func (root *Tree) Delete(dst []string) error {
 			tr.Childs = append(tr.Childs, tw.Childs[i])
 		}
 	}
+	tr.unindex()
 
-	return nil
+	dst = appendName(dst)
+	return root.record(func(root *Tree) error {
+		tr, err := root.Find(dst[:len(dst)-1])
+		if err != nil {
+			return err
+		}
+
+		if index > len(tr.Childs) {
+			index = len(tr.Childs)
+		}
+		w, err := saved.clone()
+		if err != nil {
+			return err
+		}
+		tr.Childs = append(tr.Childs, twig{})
+		copy(tr.Childs[index+1:], tr.Childs[index:])
+		tr.Childs[index] = w
+		tr.unindex()
+		return nil
+	}, func(root *Tree) error {
+		return root.deleteNode(dst)
+	}, Operation{Op: "remove", Path: dst})
 }
 
-func (root *Tree) Copy(src []string, dst []string) error {
+// Copy copies the childs of src to dst, refs are copied as refs
+// unless materialize is given, then they become copies of their targets.
+func (root *Tree) Copy(src []string, dst []string, materialize ...bool) error {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	return root.copyNode(src, dst, materialize...)
+}
+
+func (root *Tree) copyNode(src []string, dst []string, materialize ...bool) error {
 	var _copy func(src *twig, dst *twig) error
 
+	m := (len(materialize) != 0) && materialize[0]
 	_copy = func(src *twig, dst *twig) error {
 		for i := range src.Childs {
 			child := src.Childs[i]
 
-			var w twig
-			w.set(child.Name, child.Value)
+			if m {
+				w, err := root.materialize(&child, nil)
+				if err != nil {
+					return err
+				}
+				dst.Childs = append(dst.Childs, w)
+				continue
+			}
+
+			w := twig{Name: child.Name, Kind: child.Kind, Value: child.Value, Desc: child.Desc}
 			err := _copy(&child, &w)
 			if err != nil {
 				return err
@@ -529,6 +829,9 @@ func (root *Tree) Copy(src []string, dst []string) error {
 	}
 
 	fs, err := root.Find(src)
+	if err == nil {
+		err = fs.loadAll()
+	}
 	if err != nil {
 		return err
 	}
@@ -538,17 +841,46 @@ func (root *Tree) Copy(src []string, dst []string) error {
 		return err
 	}
 
+	n := len(fd.Childs)
+
 	var tw twig
 	tw.set(fs.Name, fs.Value)
-	_copy(fs, &tw)
+	err = _copy(fs, &tw)
+	if err != nil {
+		return err
+	}
 	for i := range tw.Childs {
 		fd.Childs = append(fd.Childs, tw.Childs[i])
 	}
 
-	return nil
+	src = appendName(src)
+	dst = appendName(dst)
+	op := Operation{Op: "copy", Path: dst, From: src}
+	if m {
+		op.Value = true
+	}
+	return root.record(func(root *Tree) error {
+		fd, err := root.Find(dst)
+		if err != nil {
+			return err
+		}
+
+		fd.Childs = fd.Childs[:n]
+		fd.unindex()
+		return nil
+	}, func(root *Tree) error {
+		return root.copyNode(src, dst, m)
+	}, op)
 }
 
 func (root *Tree) Move(src []string, dst []string) error {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	return root.moveNode(src, dst)
+}
+
+func (root *Tree) moveNode(src []string, dst []string) error {
 	ts, err := root.Find(src)
 	if err != nil {
 		return err
@@ -563,21 +895,56 @@ func (root *Tree) Move(src []string, dst []string) error {
 		return err
 	}
 
+	n := len(td.Childs)
+	var saved []twig
 	for i := range ts.Childs {
-		sw := true
-		for j := range list {
-			if list[j] == ts.Childs[i].Name {
-				sw = false
-				break
-			}
+		w, err := ts.Childs[i].clone()
+		if err != nil {
+			return err
 		}
+		saved = append(saved, w)
+	}
+
+	exist := make(map[string]bool, len(list))
+	for i := range list {
+		exist[list[i]] = true
+	}
 
-		if sw {
-			td.Childs = append(td.Childs, ts.Childs[i])
+	for i := range ts.Childs {
+		if !exist[ts.Childs[i].Name] {
+			td.add(ts.Childs[i])
 		}
 	}
 	ts.Childs = nil
-	return nil
+	ts.unindex()
+
+	src = appendName(src)
+	dst = appendName(dst)
+	return root.record(func(root *Tree) error {
+		td, err := root.Find(dst)
+		if err != nil {
+			return err
+		}
+		td.Childs = td.Childs[:n]
+		td.unindex()
+
+		ts, err := root.Find(src)
+		if err != nil {
+			return err
+		}
+		ts.Childs = nil
+		ts.unindex()
+		for i := range saved {
+			w, err := saved[i].clone()
+			if err != nil {
+				return err
+			}
+			ts.add(w)
+		}
+		return nil
+	}, func(root *Tree) error {
+		return root.moveNode(src, dst)
+	}, Operation{Op: "move", Path: dst, From: src})
 }
 
 func (root *Tree) GetValue(src []string) (any, error) {
@@ -585,7 +952,7 @@ func (root *Tree) GetValue(src []string) (any, error) {
 	var result any
 
 	tw, err := root.Find(src)
-	if err != err {
+	if err != nil {
 		return nil, err
 	}
 
@@ -597,6 +964,11 @@ func (root *Tree) GetValue(src []string) (any, error) {
 		return nil, fmt.Errorf("kind is null: %v", src)
 	}
 
+	tw, err = root.reveal(tw)
+	if err != nil {
+		return nil, err
+	}
+
 	result = tw.get()
 
 	return result, nil
@@ -615,6 +987,11 @@ func (root *Tree) GetValueStr(src []string) (string, error) {
 		return result, fmt.Errorf("value is null: %v", src)
 	}
 
+	tw, err = root.reveal(tw)
+	if err != nil {
+		return result, err
+	}
+
 	result = tw.get("string").(string)
 
 	return result, nil
@@ -633,6 +1010,11 @@ func (root *Tree) GetValueInt(src []string) (int64, error) {
 		return result, fmt.Errorf("value is null: %v", src)
 	}
 
+	tw, err = root.reveal(tw)
+	if err != nil {
+		return result, err
+	}
+
 	result = tw.get("integer").(int64)
 
 	return result, nil
@@ -651,6 +1033,11 @@ func (root *Tree) GetValueFloat(src []string) (float64, error) {
 		return result, fmt.Errorf("value is null: %v", src)
 	}
 
+	tw, err = root.reveal(tw)
+	if err != nil {
+		return result, err
+	}
+
 	result = tw.get("float").(float64)
 
 	return result, nil
@@ -669,23 +1056,93 @@ func (root *Tree) GetValueBool(src []string) (bool, error) {
 		return result, fmt.Errorf("value is null: %v", src)
 	}
 
+	tw, err = root.reveal(tw)
+	if err != nil {
+		return result, err
+	}
+
 	result = tw.get("bool").(bool)
 
 	return result, nil
 }
 
 func (root *Tree) SetValue(value any, src []string) error {
-	tw, err := root.Find(src)
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	return root.setValue(value, src)
+}
+
+func (root *Tree) setValue(value any, src []string) error {
+	// a Ref changes the ref node itself, other values are written to its target
+	_, final := value.(Ref)
+	tw, _, err := root.resolve(src, !final, nil)
 	if err != nil {
 		return err
 	}
 
+	kind := tw.Kind
+	old := tw.Value
 	err = tw.set(tw.Name, value)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	src = appendName(src)
+	return root.record(func(root *Tree) error {
+		tw, _, err := root.resolve(src, !final, nil)
+		if err != nil {
+			return err
+		}
+
+		tw.Kind = kind
+		tw.Value = old
+		return nil
+	}, func(root *Tree) error {
+		return root.setValue(value, src)
+	}, Operation{Op: "replace", Path: src, Value: tw.Value, Kind: tw.Kind})
+}
+
+func (root *Tree) SetDesc(desc string, src []string) error {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	return root.setDesc(desc, src)
+}
+
+func (root *Tree) setDesc(desc string, src []string) error {
+	tw, err := root.Find(src)
+	if err != nil {
+		return err
+	}
+
+	old := tw.Desc
+	tw.Desc = desc
+
+	src = appendName(src)
+	return root.record(func(root *Tree) error {
+		return root.setDesc(old, src)
+	}, func(root *Tree) error {
+		return root.setDesc(desc, src)
+	}, Operation{Op: "desc", Path: src, Value: desc})
+}
+
+func (root *Tree) GetDesc(src []string) (string, error) {
+	tw, err := root.Find(src)
+	if err != nil {
+		return "", err
+	}
+
+	return tw.Desc, nil
+}
+
+func (root *Tree) GetKind(src []string) (string, error) {
+	tw, err := root.Find(src)
+	if err != nil {
+		return "", err
+	}
+
+	return tw.Kind, nil
 }
 
 func (root *Tree) GetString(sep string, src []string) (string, error) {
@@ -703,7 +1160,7 @@ func (root *Tree) GetString(sep string, src []string) (string, error) {
 
 	for i := range tw.Childs {
 		ctw := tw.Childs[i]
-		v = ctw.get("string").(string)
+		v = root.printValue(appendName(src, ctw.Name), &ctw)
 
 		result = result + ctw.Name + "=" + v
 
@@ -715,8 +1172,18 @@ func (root *Tree) GetString(sep string, src []string) (string, error) {
 }
 
 func (root *Tree) SetMaping(m map[string]any, src []string) error {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	return root.setMaping(m, src)
+}
+
+func (root *Tree) setMaping(m map[string]any, src []string) error {
 	var name string
 
+	root.beginGroup()
+	defer root.endGroup()
+
 	if v, ok := m["name"]; ok {
 		name = v.(string)
 	} else {
@@ -726,7 +1193,7 @@ func (root *Tree) SetMaping(m map[string]any, src []string) error {
 	dst := append(src, name)
 	_, err := root.Find(dst)
 	if err != nil {
-		err := root.AddNew(name, nil, src)
+		err := root.addNew(name, nil, src)
 		if err != nil {
 			return err
 		}
@@ -740,12 +1207,12 @@ func (root *Tree) SetMaping(m map[string]any, src []string) error {
 		chd := append(dst, k)
 		_, err := root.Find(chd)
 		if err != nil {
-			err = root.AddNew(k, m[k], dst)
+			err = root.addNew(k, m[k], dst)
 			if err != nil {
 				return err
 			}
 		} else {
-			err = root.SetValue(m[k], chd)
+			err = root.setValue(m[k], chd)
 			if err != nil {
 				return err
 			}
@@ -756,9 +1223,19 @@ func (root *Tree) SetMaping(m map[string]any, src []string) error {
 }
 
 func (root *Tree) SetMapings(ms []map[string]any, src []string) error {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	return root.setMapings(ms, src)
+}
+
+func (root *Tree) setMapings(ms []map[string]any, src []string) error {
+	root.beginGroup()
+	defer root.endGroup()
+
 	for i := range ms {
 		m := ms[i]
-		err := root.SetMaping(m, src)
+		err := root.setMaping(m, src)
 		if err != nil {
 			return err
 		}
@@ -772,12 +1249,19 @@ func (root *Tree) GetMaping(name string, src []string) (map[string]any, error) {
 	if err != nil {
 		return nil, err
 	}
+	return root.maping(tw, "name")
+}
 
+// maping returns the childs of tw as a map, the node name is stored under key.
+func (root *Tree) maping(tw *twig, key string) (map[string]any, error) {
 	m := make(map[string]any)
-	m["name"] = tw.Name
+	m[key] = tw.Name
 
 	for i := range tw.Childs {
-		ctw := tw.Childs[i]
+		ctw, err := root.reveal(&tw.Childs[i])
+		if err != nil {
+			return nil, err
+		}
 		n := ctw.Name
 		v := ctw.get()
 
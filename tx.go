@@ -0,0 +1,187 @@
+package tree
+
+import (
+	"fmt"
+)
+
+// Tx collects mutations on a private copy of the tree.
+// Nothing is visible to the tree until Commit.
+type Tx struct {
+	root    *Tree
+	work    *Tree
+	base    *twig
+	version uint64
+	err     error
+}
+
+func (root *Tree) Begin() *Tx {
+	root.mu.RLock()
+	defer root.mu.RUnlock()
+
+	work, err := root.clone()
+	return &Tx{root: root, work: work, base: root.Base, version: root.version, err: err}
+}
+
+func (root *Tree) View(fn func(t *Tree) error) error {
+	root.mu.RLock()
+	defer root.mu.RUnlock()
+
+	return fn(root)
+}
+
+func (root *Tree) Update(fn func(tx *Tx) error, save ...bool) error {
+	tx := root.Begin()
+
+	err := fn(tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit(save...)
+}
+
+func (tx *Tx) tree() (*Tree, error) {
+	if tx.err != nil {
+		return nil, tx.err
+	}
+	if tx.work == nil {
+		return nil, fmt.Errorf("transaction is closed")
+	}
+	return tx.work, nil
+}
+
+func (tx *Tx) Commit(save ...bool) error {
+	work, err := tx.tree()
+	if err != nil {
+		return err
+	}
+
+	root := tx.root
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	tx.work = nil
+	if root.version != tx.version {
+		return fmt.Errorf("tree changed since begin")
+	}
+
+	before, err := root.checkpoint()
+	if err != nil {
+		return err
+	}
+	root.Base = work.Base
+	err = root.recordSince(before)
+
+	if (err == nil) && (len(save) != 0) && save[0] {
+		err = root.save()
+		if err != nil {
+			root.Base = tx.base
+			root.version++
+			root.forget()
+			return err
+		}
+	}
+
+	return err
+}
+
+func (tx *Tx) Rollback() {
+	tx.work = nil
+}
+
+func (tx *Tx) List(names []string) ([]string, error) {
+	work, err := tx.tree()
+	if err != nil {
+		return nil, err
+	}
+	return work.List(names)
+}
+
+func (tx *Tx) GetValue(src []string) (any, error) {
+	work, err := tx.tree()
+	if err != nil {
+		return nil, err
+	}
+	return work.GetValue(src)
+}
+
+func (tx *Tx) AddNew(name string, value any, dst []string) error {
+	work, err := tx.tree()
+	if err != nil {
+		return err
+	}
+	return work.AddNew(name, value, dst)
+}
+
+func (tx *Tx) Delete(dst []string) error {
+	work, err := tx.tree()
+	if err != nil {
+		return err
+	}
+	return work.Delete(dst)
+}
+
+func (tx *Tx) Copy(src []string, dst []string, materialize ...bool) error {
+	work, err := tx.tree()
+	if err != nil {
+		return err
+	}
+	return work.Copy(src, dst, materialize...)
+}
+
+func (tx *Tx) Move(src []string, dst []string) error {
+	work, err := tx.tree()
+	if err != nil {
+		return err
+	}
+	return work.Move(src, dst)
+}
+
+func (tx *Tx) SetValue(value any, src []string) error {
+	work, err := tx.tree()
+	if err != nil {
+		return err
+	}
+	return work.SetValue(value, src)
+}
+
+func (tx *Tx) SetDesc(desc string, src []string) error {
+	work, err := tx.tree()
+	if err != nil {
+		return err
+	}
+	return work.SetDesc(desc, src)
+}
+
+func (tx *Tx) SetMaping(m map[string]any, src []string) error {
+	work, err := tx.tree()
+	if err != nil {
+		return err
+	}
+	return work.SetMaping(m, src)
+}
+
+func (tx *Tx) SetMapings(ms []map[string]any, src []string) error {
+	work, err := tx.tree()
+	if err != nil {
+		return err
+	}
+	return work.SetMapings(ms, src)
+}
+
+func (tx *Tx) Merge(src []string, dst []string, strategy MergeStrategy) ([]Change, error) {
+	work, err := tx.tree()
+	if err != nil {
+		return nil, err
+	}
+	return work.Merge(src, dst, strategy)
+}
+
+func (tx *Tx) Apply(patch []Operation) error {
+	work, err := tx.tree()
+	if err != nil {
+		return err
+	}
+	return work.Apply(patch)
+}
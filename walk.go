@@ -0,0 +1,99 @@
+package tree
+
+import (
+	"errors"
+	"iter"
+)
+
+// WalkFunc is called for every node, path is the full path of the node.
+// Returning SkipSubtree skips the childs of the node, SkipAll stops the walk.
+type WalkFunc func(path []string, node Node) error
+
+var (
+	SkipSubtree = errors.New("skip subtree")
+	SkipAll     = errors.New("skip all")
+)
+
+type WalkOrder int
+
+const (
+	DepthFirst WalkOrder = iota
+	BreadthFirst
+)
+
+type walkItem struct {
+	tw    *twig
+	path  []string
+	depth int
+}
+
+// Walk visits src and all nodes below it depth first.
+// The tree must not be changed during the walk.
+func (root *Tree) Walk(src []string, fn WalkFunc) error {
+	return root.WalkWith(src, DepthFirst, 0, fn)
+}
+
+// WalkWith visits src and the nodes below it up to maxDepth levels (0 is no limit).
+func (root *Tree) WalkWith(src []string, order WalkOrder, maxDepth int, fn WalkFunc) error {
+	tw, err := root.Find(src)
+	if err != nil {
+		return err
+	}
+
+	queue := []walkItem{{tw: tw, path: appendName(src), depth: 0}}
+	for len(queue) != 0 {
+		var it walkItem
+		if order == BreadthFirst {
+			it = queue[0]
+			queue = queue[1:]
+		} else {
+			it = queue[len(queue)-1]
+			queue = queue[:len(queue)-1]
+		}
+
+		err = fn(appendName(it.path), root.node(it.tw, it.path))
+		if err == SkipAll {
+			return nil
+		}
+		if err == SkipSubtree {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if (maxDepth > 0) && (it.depth >= maxDepth) {
+			continue
+		}
+
+		err = it.tw.load()
+		if err != nil {
+			return err
+		}
+
+		n := len(it.tw.Childs)
+		for i := range it.tw.Childs {
+			// depth first pops from the end, push in reverse to keep the order
+			j := i
+			if order != BreadthFirst {
+				j = n - 1 - i
+			}
+			ctw := &it.tw.Childs[j]
+			queue = append(queue, walkItem{tw: ctw, path: appendName(it.path, ctw.Name), depth: it.depth + 1})
+		}
+	}
+
+	return nil
+}
+
+// All iterates over every node of the tree depth first, for use with range.
+func (root *Tree) All() iter.Seq2[[]string, Node] {
+	return func(yield func([]string, Node) bool) {
+		root.Walk(nil, func(path []string, node Node) error {
+			if !yield(path, node) {
+				return SkipAll
+			}
+			return nil
+		})
+	}
+}